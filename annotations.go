@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// annotator adds virtual text after the value of a line, like the date of
+// a timestamp. It only changes what is shown, never the data.
+type annotator struct {
+	name     string
+	annotate func(line LineMetadata) string
+}
+
+// Annotators in the order their text is shown. Each one can be turned
+// off with :set no<name>.
+var annotators = []annotator{
+	{"timestamps", annotateTimestamp},
+	{"ages", annotateAge},
+	{"colors", annotateColor},
+	{"sizes", annotateSize},
+}
+
+// Annotators turned off with :set
+var disabledAnnotators = make(map[string]bool)
+
+// now returns the current time, to compute the age of dates
+var now = time.Now
+
+// Unix timestamps from 2001-09-09 to 2100, in seconds, milliseconds,
+// microseconds or nanoseconds
+var timestampUnits = []struct {
+	min, max int64
+	unit     time.Duration
+}{
+	{1e9, 4102444800, time.Second},
+	{1e12, 4102444800e3, time.Millisecond},
+	{1e15, 4102444800e6, time.Microsecond},
+	{1e18, 4102444800e9, time.Nanosecond},
+}
+
+var (
+	colorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	sizeKey      = regexp.MustCompile(`(?i)(size|bytes|content[-_]?length)$`)
+)
+
+// annotationText returns the virtual text of the enabled annotators for
+// the line, or "" when there is none
+func annotationText(line LineMetadata) string {
+	if line.LineType != ContentLine {
+		return ""
+	}
+
+	var parts []string
+	for _, a := range annotators {
+		if disabledAnnotators[a.name] {
+			continue
+		}
+		if text := a.annotate(line); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// setAnnotator runs a :set command: "name" turns an annotator on,
+// "noname" turns it off and "name!" toggles it
+func setAnnotator(option string) error {
+	name, toggle := strings.CutSuffix(option, "!")
+	enable := true
+	if !toggle && strings.HasPrefix(name, "no") && !isAnnotator(name) {
+		name, enable = strings.TrimPrefix(name, "no"), false
+	}
+	if !isAnnotator(name) {
+		return fmt.Errorf("unknown option: %s", option)
+	}
+
+	if toggle {
+		enable = disabledAnnotators[name]
+	}
+	if enable {
+		delete(disabledAnnotators, name)
+	} else {
+		disabledAnnotators[name] = true
+	}
+	return nil
+}
+
+func isAnnotator(name string) bool {
+	for _, a := range annotators {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// annotatorSettings lists the annotators like vim lists options, with a
+// "no" in front of the ones that are off
+func annotatorSettings() string {
+	settings := make([]string, len(annotators))
+	for i, a := range annotators {
+		settings[i] = a.name
+		if disabledAnnotators[a.name] {
+			settings[i] = "no" + a.name
+		}
+	}
+	return strings.Join(settings, " ")
+}
+
+// annotateTimestamp shows the date of integers that look like Unix
+// timestamps
+func annotateTimestamp(line LineMetadata) string {
+	if line.NodeType != IntegerType {
+		return ""
+	}
+	n, err := strconv.ParseInt(line.Content, 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	for _, ts := range timestampUnits {
+		if n >= ts.min && n < ts.max {
+			date := time.Unix(0, n*int64(ts.unit)).UTC()
+			if ts.unit == time.Second {
+				return noteStyle.Render(date.Format(time.RFC3339))
+			}
+			return noteStyle.Render(date.Format("2006-01-02T15:04:05.000Z07:00"))
+		}
+	}
+	return ""
+}
+
+// annotateAge shows how long ago, or in how long, an ISO 8601 date is
+func annotateAge(line LineMetadata) string {
+	if line.NodeType != StringType && line.NodeType != DateTimeType {
+		return ""
+	}
+	date, err := time.Parse(time.RFC3339Nano, fmt.Sprint(line.Value))
+	if err != nil {
+		return ""
+	}
+	return noteStyle.Render(formatAge(now().Sub(date)))
+}
+
+// formatAge returns a duration in its largest unit, like "3h ago" or
+// "in 2d" for a date in the future
+func formatAge(d time.Duration) string {
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	const day = 24 * time.Hour
+	var age string
+	switch {
+	case d < time.Minute:
+		age = fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		age = fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < day:
+		age = fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 30*day:
+		age = fmt.Sprintf("%dd", d/day)
+	case d < 365*day:
+		age = fmt.Sprintf("%dmo", d/(30*day))
+	default:
+		age = fmt.Sprintf("%dy", d/(365*day))
+	}
+
+	if future {
+		return "in " + age
+	}
+	return age + " ago"
+}
+
+// annotateColor shows a swatch of #rgb and #rrggbb colors
+func annotateColor(line LineMetadata) string {
+	s, ok := line.Value.(string)
+	if line.NodeType != StringType || !ok || !colorPattern.MatchString(s) {
+		return ""
+	}
+	if len(s) == 4 {
+		// #abc is #aabbcc
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(s)).Render("██")
+}
+
+// annotateSize shows byte counts of fields like "size" or "fileBytes" in
+// a human readable unit
+func annotateSize(line LineMetadata) string {
+	if line.NodeType != IntegerType || !sizeKey.MatchString(line.Key) {
+		return ""
+	}
+	n, err := strconv.ParseInt(line.Content, 10, 64)
+	if err != nil || n < 1024 {
+		return ""
+	}
+	return noteStyle.Render(formatSize(n))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotations(t *testing.T) {
+	currentTheme = themes["nocolor"]
+	now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	tree := mustLoadTree(t, `{
+		"created": 1700000000,
+		"updatedMs": 1700000000123,
+		"count": 42,
+		"seen": "2024-01-01T09:00:00Z",
+		"due": "2024-01-03T12:00:00Z",
+		"color": "#ff8800",
+		"fileSize": 1572864,
+		"small_size": 512
+	}`)
+
+	rendered := make(map[string]string)
+	for _, line := range tree.PrintAsJSON2() {
+		if line.LineType == ContentLine {
+			rendered[tree.Path(line.NodeID)] = RenderLine(line, false)
+		}
+	}
+
+	assert.Equal(t, `  "created": 1700000000, 2023-11-14T22:13:20Z`, rendered["created"])
+	assert.Equal(t, `  "updatedMs": 1700000000123, 2023-11-14T22:13:20.123Z`, rendered["updatedMs"])
+	assert.Equal(t, `  "count": 42,`, rendered["count"])
+	assert.Equal(t, `  "seen": "2024-01-01T09:00:00Z", 3h ago`, rendered["seen"])
+	assert.Equal(t, `  "due": "2024-01-03T12:00:00Z", in 2d`, rendered["due"])
+	assert.Equal(t, `  "color": "#ff8800", ██`, rendered["color"])
+	assert.Equal(t, `  "fileSize": 1572864, 1.5 MiB`, rendered["fileSize"])
+	assert.Equal(t, `  "small_size": 512`, rendered["small_size"])
+}
+
+func TestSetAnnotator(t *testing.T) {
+	defer clear(disabledAnnotators)
+
+	line := LineMetadata{LineType: ContentLine, NodeType: IntegerType,
+		Key: "ts", Content: "1700000000"}
+	assert.NotEmpty(t, annotationText(line))
+
+	assert.NoError(t, setAnnotator("notimestamps"))
+	assert.Empty(t, annotationText(line))
+	assert.Equal(t, "notimestamps ages colors sizes", annotatorSettings())
+
+	assert.NoError(t, setAnnotator("timestamps!"))
+	assert.NotEmpty(t, annotationText(line))
+	assert.NoError(t, setAnnotator("sizes!"))
+	assert.Equal(t, "timestamps ages colors nosizes", annotatorSettings())
+
+	assert.EqualError(t, setAnnotator("nowrap"), "unknown option: nowrap")
+}
+
+func TestFormatAge(t *testing.T) {
+	assert.Equal(t, "30s ago", formatAge(30*time.Second))
+	assert.Equal(t, "5m ago", formatAge(5*time.Minute))
+	assert.Equal(t, "in 3h", formatAge(-3*time.Hour))
+	assert.Equal(t, "2mo ago", formatAge(65*24*time.Hour))
+	assert.Equal(t, "1y ago", formatAge(400*24*time.Hour))
+}
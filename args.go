@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// cliArgs are the parsed command line arguments
+type cliArgs struct {
+	opts    options
+	files   []string
+	request httpRequest // used when the file is a URL
+	command string      // shell command whose output is the document
+	help    bool
+	version bool
+}
+
+// parseArgs parses the command line arguments, without the program name.
+// Options may come before or after the file, and take their value as
+// "--name value" or "--name=value".
+func parseArgs(args []string) (cliArgs, error) {
+	var parsed cliArgs
+	opts := &parsed.opts
+
+	// The flag package prints its errors and the usage to its output,
+	// main prints the error that is returned instead
+	fs := flag.NewFlagSet("vj", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+
+	// The errors of the option values are returned as they are, without
+	// what the flag package adds in front of them
+	var valueErr error
+	check := func(fn func(string) error) func(string) error {
+		return func(value string) error {
+			valueErr = fn(value)
+			return valueErr
+		}
+	}
+
+	fs.BoolVar(&parsed.help, "h", false, "")
+	fs.BoolVar(&parsed.help, "help", false, "")
+	fs.BoolVar(&parsed.version, "v", false, "")
+	fs.BoolVar(&parsed.version, "V", false, "")
+	fs.BoolVar(&parsed.version, "version", false, "")
+
+	fs.BoolVar(&opts.ndjson, "ndjson", false, "")
+	fs.BoolVar(&opts.follow, "f", false, "")
+	fs.BoolVar(&opts.follow, "follow", false, "")
+	var lenient bool
+	fs.BoolVar(&lenient, "lenient", false, "")
+	fs.Func("format", "", check(func(name string) error {
+		if _, found := findFormat(name); !found {
+			return fmt.Errorf("unknown format %q (%s)", name,
+				strings.Join(formatNames(), ", "))
+		}
+		opts.format = name
+		return nil
+	}))
+
+	fs.Func("delimiter", "", check(func(value string) error {
+		comma, err := parseDelimiter(value)
+		opts.delimiter = comma
+		return err
+	}))
+	fs.BoolVar(&opts.noHeader, "no-header", false, "")
+	fs.BoolVar(&opts.inferTypes, "infer-types", false, "")
+
+	request := &parsed.request
+	request.header = make(http.Header)
+	addHeader := check(func(header string) error {
+		return parseHeader(request.header, header)
+	})
+	fs.Func("H", "", addHeader)
+	fs.Func("header", "", addHeader)
+	fs.StringVar(&request.method, "X", "", "")
+	fs.StringVar(&request.method, "request", "", "")
+	fs.StringVar(&request.bodyFile, "body-file", "", "")
+
+	fs.StringVar(&parsed.command, "cmd", "", "")
+
+	// The flag package stops at the first file, so parse the
+	// arguments after it again
+	for {
+		if err := fs.Parse(args); valueErr != nil {
+			return parsed, valueErr
+		} else if err != nil {
+			return parsed, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		parsed.files = append(parsed.files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(parsed.files) > 1 {
+		return parsed, fmt.Errorf("vj opens one file at a time, not %d: %s",
+			len(parsed.files), strings.Join(parsed.files, " "))
+	}
+
+	// --lenient reads JSON5 files as JSON5 and the others as JSONC,
+	// unless --format was given
+	if lenient && opts.format == "" {
+		opts.format = "jsonc"
+		if len(parsed.files) > 0 && hasExt(parsed.files[0], ".json5") {
+			opts.format = "json5"
+		}
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	parsed, err := parseArgs([]string{"--format", "csv", "data.txt",
+		"--delimiter=;", "--no-header", "-f"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data.txt"}, parsed.files)
+	assert.Equal(t, options{format: "csv", delimiter: ';', noHeader: true, follow: true},
+		parsed.opts)
+
+	parsed, err = parseArgs([]string{"--lenient", "config.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "jsonc", parsed.opts.format)
+
+	parsed, err = parseArgs([]string{"config.json5", "--lenient"})
+	assert.NoError(t, err)
+	assert.Equal(t, "json5", parsed.opts.format)
+
+	parsed, err = parseArgs([]string{"-h"})
+	assert.NoError(t, err)
+	assert.True(t, parsed.help)
+
+	parsed, err = parseArgs([]string{"--version"})
+	assert.NoError(t, err)
+	assert.True(t, parsed.version)
+}
+
+func TestParseArgs_Errors(t *testing.T) {
+	_, err := parseArgs([]string{"--format", "xml"})
+	assert.ErrorContains(t, err, `unknown format "xml" (json, jsonc`)
+
+	_, err = parseArgs([]string{"--delimiter", ";;"})
+	assert.EqualError(t, err, `the delimiter must be a single character, not ";;"`)
+
+	_, err = parseArgs([]string{"--format"})
+	assert.EqualError(t, err, "flag needs an argument: -format")
+
+	_, err = parseArgs([]string{"--bogus"})
+	assert.EqualError(t, err, "flag provided but not defined: -bogus")
+
+	_, err = parseArgs([]string{"a.json", "-f", "b.json"})
+	assert.EqualError(t, err, "vj opens one file at a time, not 2: a.json b.json")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Nesting depth of the arrays and maps past which an input is rejected,
+// like encoding/json does, before the recursion overflows the stack
+const maxNestingDepth = 10000
+
+// byteReader reads the items of a binary format like CBOR or MessagePack
+type byteReader struct {
+	src   []byte
+	pos   int
+	depth int // arrays and maps around the item being decoded
+}
+
+// enter goes down into an array or a map, leave goes back up
+func (r *byteReader) enter() error {
+	r.depth++
+	if r.depth > maxNestingDepth {
+		return fmt.Errorf("items nested more than %d levels deep", maxNestingDepth)
+	}
+	return nil
+}
+
+func (r *byteReader) leave() {
+	r.depth--
+}
+
+// read returns the next n bytes
+func (r *byteReader) read(n uint64) ([]byte, error) {
+	if n > uint64(len(r.src)-r.pos) {
+		r.pos = len(r.src)
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.src[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes
+func (r *byteReader) readUint(size int) (uint64, error) {
+	b, err := r.read(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// count returns a number of items to read as an int, failing when the
+// input is too short to hold them, as every item takes at least one byte
+func (r *byteReader) count(n uint64) (int, error) {
+	if n > uint64(len(r.src)-r.pos) {
+		r.pos = len(r.src)
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), nil
+}
+
+// floatLiteral writes a float as a JSON number literal. Whole numbers keep
+// a fraction, so they are still shown as floats.
+func floatLiteral(f float64, bitSize int) json.Number {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+
+	literal := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(literal, ".e") {
+		literal += ".0"
+	}
+	return json.Number(literal)
+}
+
+// mapKey returns the object key of a map key that is not a string
+func mapKey(key interface{}) string {
+	key, _ = unwrapValue(key)
+
+	switch key := key.(type) {
+	case string:
+		return key
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(key)
+}
+
+// Self-described CBOR, tag 55799, marks a CBOR document
+var cborMagic = []byte{0xd9, 0xd9, 0xf7}
+
+// Bytes of the input that sniffBinaryFormat decodes
+const sniffSize = 4096
+
+// sniffBinaryFormat guesses the format of input that is not text: "cbor"
+// or "msgpack", or an empty string. JSON starts with an ASCII character,
+// while the maps and arrays of both binary formats start with a byte of
+// 0x80 or more, so only such input is checked. Only the start of the
+// first item is decoded: the detectors of both formats call it.
+func sniffBinaryFormat(input []byte) string {
+	if bytes.HasPrefix(input, cborMagic) {
+		return "cbor"
+	}
+	if len(input) == 0 || input[0] < 0x80 || bytes.HasPrefix(input, byteOrderMark) {
+		return ""
+	}
+
+	// 0x80-0x9f are maps and arrays in MessagePack, arrays in CBOR
+	formats := []string{"cbor", "msgpack"}
+	if input[0] <= 0x8f || input[0] >= 0xdc && input[0] <= 0xdf {
+		formats = []string{"msgpack", "cbor"}
+	}
+
+	// An item cut at the end of the sniffed bytes still matches
+	head := input[:min(len(input), sniffSize)]
+	truncated := len(head) < len(input)
+	for _, format := range formats {
+		err := decodeFirstItem(head, format)
+		if err == nil || truncated && errors.Is(err, io.ErrUnexpectedEOF) {
+			return format
+		}
+	}
+	return ""
+}
+
+// decodeFirstItem decodes the first item of a CBOR or MessagePack input
+func decodeFirstItem(input []byte, format string) error {
+	var err error
+	if format == "cbor" {
+		d := cborDecoder{byteReader: byteReader{src: input}}
+		_, err = d.item()
+	} else {
+		d := msgpackDecoder{byteReader: byteReader{src: input}}
+		_, err = d.item()
+	}
+	return err
+}
+
+// decodeBinary decodes the items of a CBOR or MessagePack input
+func decodeBinary(input []byte, format string) ([]interface{}, error) {
+	if format == "cbor" {
+		return ParseCBOR(input)
+	}
+	return ParseMsgpack(input)
+}
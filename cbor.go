@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// CBOR major types
+const (
+	cborUnsigned = iota
+	cborNegative
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// Additional information of an indefinite length item, and the byte that
+// ends its content
+const (
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+// Names of the common CBOR tags, shown in the note of tagged values
+var cborTagNames = map[uint64]string{
+	0:  "date/time",
+	1:  "epoch time",
+	2:  "bignum",
+	3:  "negative bignum",
+	4:  "decimal fraction",
+	5:  "bigfloat",
+	21: "base64url",
+	22: "base64",
+	23: "base16",
+	24: "embedded CBOR",
+	32: "URI",
+	35: "regexp",
+	36: "MIME message",
+}
+
+// Tag 55799 only marks the input as CBOR
+const cborSelfDescribed = 55799
+
+// ParseCBOR decodes a CBOR sequence (RFC 8949) and returns its items.
+// Byte strings are Bytes, date/time strings DateTime values and bignums
+// numbers; the tags are kept as notes.
+//
+// On invalid input it returns a *ParseError and the items decoded before
+// the error.
+func ParseCBOR(src []byte) ([]interface{}, error) {
+	d := cborDecoder{byteReader{src: src}}
+
+	var items []interface{}
+	for d.pos < len(src) {
+		item, err := d.item()
+		if err != nil {
+			return items, &ParseError{Err: err, Offset: int64(d.pos)}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+type cborDecoder struct {
+	byteReader
+}
+
+// item decodes the next data item
+func (d *cborDecoder) item() (interface{}, error) {
+	head, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	major, info := head[0]>>5, head[0]&0x1f
+
+	if major == cborArray || major == cborMap || major == cborTag {
+		if err := d.enter(); err != nil {
+			return nil, err
+		}
+		defer d.leave()
+	}
+
+	if major == cborSimple {
+		return d.simple(info)
+	}
+
+	if info == cborIndefinite {
+		return d.indefinite(major)
+	}
+
+	arg, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		return json.Number(strconv.FormatUint(arg, 10)), nil
+
+	case cborNegative:
+		// The value is -1 - arg, which may not fit in an int64
+		n := new(big.Int).SetUint64(arg)
+		return json.Number(n.Neg(n.Add(n, big.NewInt(1))).String()), nil
+
+	case cborBytes:
+		b, err := d.read(arg)
+		return Bytes(append([]byte{}, b...)), err
+
+	case cborText:
+		b, err := d.read(arg)
+		return string(b), err
+
+	case cborArray:
+		n, err := d.count(arg)
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := d.item()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+
+	case cborMap:
+		n, err := d.count(arg)
+		if err != nil {
+			return nil, err
+		}
+		obj := make(Object, 0, n)
+		for i := 0; i < n; i++ {
+			member, err := d.member()
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member)
+		}
+		return obj, nil
+	}
+
+	// cborTag
+	item, err := d.item()
+	if err != nil {
+		return nil, err
+	}
+	return cborTagged(arg, item), nil
+}
+
+// argument reads the argument of an item head: its value, length or
+// number of items
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return d.readUint(1 << (info - 24))
+	}
+	return 0, fmt.Errorf("invalid additional information %d", info)
+}
+
+// member decodes a key and its value
+func (d *cborDecoder) member() (Member, error) {
+	key, err := d.item()
+	if err != nil {
+		return Member{}, err
+	}
+	value, err := d.item()
+	return Member{Key: mapKey(key), Value: value}, err
+}
+
+// indefinite decodes an indefinite length item, whose content ends with a
+// break byte
+func (d *cborDecoder) indefinite(major byte) (interface{}, error) {
+	var chunks []byte
+	arr := []interface{}{}
+	obj := Object{}
+
+	for {
+		if d.pos < len(d.src) && d.src[d.pos] == cborBreak {
+			d.pos++
+			break
+		}
+
+		switch major {
+		case cborBytes, cborText:
+			// The chunks are definite length strings of the same type
+			if d.pos < len(d.src) && d.src[d.pos]>>5 != major {
+				return nil, errors.New("invalid chunk in indefinite length string")
+			}
+			chunk, err := d.item()
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := chunk.(string); ok {
+				chunks = append(chunks, s...)
+			} else {
+				chunks = append(chunks, chunk.(Bytes)...)
+			}
+
+		case cborArray:
+			item, err := d.item()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+
+		case cborMap:
+			member, err := d.member()
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member)
+
+		default:
+			return nil, fmt.Errorf("invalid indefinite length for major type %d", major)
+		}
+	}
+
+	switch major {
+	case cborBytes:
+		return Bytes(chunks), nil
+	case cborText:
+		return string(chunks), nil
+	case cborArray:
+		return arr, nil
+	}
+	return obj, nil
+}
+
+// simple decodes the items of major type 7: booleans, null, undefined,
+// floats and other simple values
+func (d *cborDecoder) simple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22:
+		return nil, nil
+	case 23:
+		return Annotated{Value: nil, Note: "undefined"}, nil
+
+	case 24:
+		n, err := d.readUint(1)
+		return Annotated{Value: json.Number(strconv.FormatUint(n, 10)),
+			Note: "simple value"}, err
+
+	case 25:
+		bits, err := d.readUint(2)
+		return floatLiteral(float64(halfToFloat32(uint16(bits))), 32), err
+	case 26:
+		bits, err := d.readUint(4)
+		return floatLiteral(float64(math.Float32frombits(uint32(bits))), 32), err
+	case 27:
+		bits, err := d.readUint(8)
+		return floatLiteral(math.Float64frombits(bits), 64), err
+
+	case cborIndefinite:
+		return nil, errors.New("unexpected break")
+	}
+
+	if info < 20 {
+		return Annotated{Value: json.Number(strconv.Itoa(int(info))),
+			Note: "simple value"}, nil
+	}
+	return nil, fmt.Errorf("invalid simple value %d", info)
+}
+
+// halfToFloat32 converts an IEEE 754 half-precision float
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+
+	switch exp {
+	case 0:
+		// Zero or subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		// Infinity or NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
+
+// cborTagged converts the content of well-known tags and keeps the tag
+// as a note
+func cborTagged(tag uint64, item interface{}) interface{} {
+	if tag == cborSelfDescribed {
+		return item
+	}
+
+	switch content := item.(type) {
+	case string:
+		if tag == 0 {
+			item = DateTime(content)
+		}
+	case Bytes:
+		if tag == 2 || tag == 3 {
+			n := new(big.Int).SetBytes(content)
+			if tag == 3 {
+				n.Neg(n.Add(n, big.NewInt(1)))
+			}
+			item = json.Number(n.String())
+		}
+	}
+
+	note := "tag " + strconv.FormatUint(tag, 10)
+	if name, known := cborTagNames[tag]; known {
+		note += " (" + name + ")"
+	}
+	return annotate(item, []string{note})
+}
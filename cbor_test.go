@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseCBOR(t *testing.T) {
+	// Examples from RFC 8949, appendix A
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"17", json.Number("23")},
+		{"1b ffffffffffffffff", json.Number("18446744073709551615")},
+		{"38 63", json.Number("-100")},
+		{"3b ffffffffffffffff", json.Number("-18446744073709551616")},
+		{"f9 3c00", json.Number("1.0")},
+		{"f9 7c00", json.Number("Infinity")},
+		{"f9 0001", json.Number("5.9604645e-08")},
+		{"fb 3ff199999999999a", json.Number("1.1")},
+		{"f4", false},
+		{"f6", nil},
+		{"f7", Annotated{Value: nil, Note: "undefined"}},
+		{"44 01020304", Bytes{1, 2, 3, 4}},
+		{"62 c3bc", "ü"},
+		{"a2 61 61 01 61 62 82 02 03", Object{
+			{Key: "a", Value: json.Number("1")},
+			{Key: "b", Value: []interface{}{json.Number("2"), json.Number("3")}},
+		}},
+		{"a1 01 02", Object{{Key: "1", Value: json.Number("2")}}},
+		// Indefinite length items
+		{"5f 42 0102 43 030405 ff", Bytes{1, 2, 3, 4, 5}},
+		{"7f 65 7374726561 64 6d696e67 ff", "streaming"},
+		{"9f 01 82 02 03 ff", []interface{}{json.Number("1"),
+			[]interface{}{json.Number("2"), json.Number("3")}}},
+		{"bf 61 61 01 ff", Object{{Key: "a", Value: json.Number("1")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			items, err := ParseCBOR(mustDecodeHex(t, tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{tt.expected}, items)
+		})
+	}
+}
+
+func TestParseCBOR_Tags(t *testing.T) {
+	input := mustDecodeHex(t, "a3"+
+		"64 64617465 c0 74 323031332d30332d32315432303a30343a30305a"+
+		"63 626967 c2 49 010000000000000000"+
+		"63 75726c d8 20 76 687474703a2f2f7777772e6578616d706c652e636f6d")
+
+	tree, err := loadTree(input, options{format: "cbor"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, DateTimeType, tree.Node("date").Type)
+	assert.Equal(t, DateTime("2013-03-21T20:04:00Z"), mustGetValue(t, tree, "date"))
+	assert.Equal(t, "tag 0 (date/time)", tree.Node("date").Note)
+
+	assert.Equal(t, json.Number("18446744073709551616"), mustGetValue(t, tree, "big"))
+	assert.Equal(t, "tag 2 (bignum)", tree.Node("big").Note)
+
+	assert.Equal(t, "http://www.example.com", mustGetValue(t, tree, "url"))
+	assert.Equal(t, "tag 32 (URI)", tree.Node("url").Note)
+}
+
+func TestParseCBOR_Sequence(t *testing.T) {
+	// Self-described CBOR is recognized without a format
+	tree, err := loadTree(mustDecodeHex(t, "d9d9f7 a1 61 61 01 a1 61 61 02"), options{})
+	assert.NoError(t, err)
+	assert.True(t, tree.Stream)
+	assert.Equal(t, json.Number("2"), mustGetValue(t, tree, "1.a"))
+}
+
+func TestParseCBOR_TooDeep(t *testing.T) {
+	input := bytes.Repeat([]byte{0x81}, maxNestingDepth+1)
+
+	_, err := ParseCBOR(append(input, 0x01))
+	assert.ErrorContains(t, err, "items nested more than 10000 levels deep")
+
+	// Up to the limit, the items are decoded
+	items, err := ParseCBOR(append(input[1:], 0x01))
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func TestParseCBOR_Error(t *testing.T) {
+	input := mustDecodeHex(t, "01 82 01")
+
+	items, err := ParseCBOR(input)
+	assert.Equal(t, []interface{}{json.Number("1")}, items)
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, int64(3), parseErr.Offset)
+
+	_, err = ParseCBOR(mustDecodeHex(t, "ff"))
+	assert.EqualError(t, err, "byte 1: unexpected break")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shellCommand is the command whose output is the document, run again
+// with r or :reload
+type shellCommand struct {
+	line   string
+	format string // given with --format, otherwise detected on every run
+}
+
+// Number of lines at the end of stderr shown with the parse error of
+// the output
+const stderrTailLines = 5
+
+// commandMsg carries the result of a run of the command
+type commandMsg struct {
+	reload reloadMsg
+	line   string
+	stderr string // the last lines the command printed on stderr
+	err    error  // the command could not run, or exited with an error
+}
+
+// runShell runs the command line with the shell and returns its stdout
+// and the last lines of its stderr. When the command fails, the error
+// includes the last line of stderr, which usually says why.
+func runShell(line string) ([]byte, string, error) {
+	cmd := exec.Command("sh", "-c", line)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", line)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	tail := strings.Join(lines[max(len(lines)-stderrTailLines, 0):], "\n")
+	if err != nil {
+		if reason := strings.TrimSpace(lines[len(lines)-1]); reason != "" {
+			err = fmt.Errorf("%w: %s", err, reason)
+		}
+	}
+	return stdout.Bytes(), tail, err
+}
+
+// execCommand runs the command in the background and parses its output
+func execCommand(command shellCommand, opts options) tea.Cmd {
+	return func() tea.Msg {
+		output, stderr, err := runShell(command.line)
+		if err != nil {
+			return commandMsg{line: command.line, stderr: stderr, err: err}
+		}
+
+		input, compressed, err := readInput(bytes.NewReader(output))
+		if err != nil {
+			return commandMsg{line: command.line, stderr: stderr, err: err}
+		}
+
+		opts.format = command.format
+		if opts.format == "" {
+			opts.format = detectFormat("", input)
+		}
+		tree, err := loadTree(input, opts)
+		return commandMsg{line: command.line, stderr: stderr, reload: reloadMsg{
+			tree: tree, source: input, compressed: compressed,
+			format: opts.format, err: err,
+		}}
+	}
+}
+
+// startCommand runs a new command, its output replaces the document
+func (m model) startCommand(line string) (tea.Model, tea.Cmd) {
+	command := shellCommand{line: line}
+	if m.command != nil {
+		command.format = m.command.format
+	}
+
+	m.stopLoading()
+	m.command = &command
+	m.request, m.filePath = nil, ""
+	m.mode = Normal
+	m.statusBar = "Running: " + line
+	return m, execCommand(command, m.opts)
+}
+
+// applyCommand shows the output of the command. Like a reload, folds and
+// the cursor are kept by path. When the command fails the document stays,
+// and the error is shown in the status bar.
+func (m model) applyCommand(msg commandMsg) (tea.Model, tea.Cmd) {
+	if m.command == nil || msg.line != m.command.line {
+		// The output of a command that was replaced by :run
+		return m, nil
+	}
+	m.stderr = msg.stderr
+	if msg.err != nil {
+		m.mode = Error
+		m.statusBar = errorStyle.Render("Error: " + msg.err.Error())
+		return m, nil
+	}
+
+	// The empty tree of the first run has nothing to keep showing on a
+	// parse error, it stays until a tree replaces it
+	firstRun := m.tree.Len() == 0
+	if firstRun {
+		var parseErr *ParseError
+		if errors.As(msg.reload.err, &parseErr) {
+			m.mode = ParseFailed
+		}
+	}
+
+	updated, cmd := m.applyReload(msg.reload)
+	m = updated.(model)
+	if firstRun && m.mode == Normal {
+		m.statusBar = summarizeDuplicates(m.tree)
+	}
+	return m, cmd
+}
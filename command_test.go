@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunShell(t *testing.T) {
+	output, stderr, err := runShell(`echo '{"a": 1}'`)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"a\": 1}\n", string(output))
+	assert.Equal(t, "", stderr)
+
+	_, stderr, err = runShell("echo warming up >&2; echo 'no such pod' >&2; exit 3")
+	assert.EqualError(t, err, "exit status 3: no such pod")
+	assert.Equal(t, "warming up\nno such pod", stderr)
+
+	// Only the end of a long stderr is kept
+	_, stderr, err = runShell("seq 1 20 >&2")
+	assert.NoError(t, err)
+	assert.Equal(t, "16\n17\n18\n19\n20", stderr)
+}
+
+// newCommandModel returns a model that ran the command once
+func newCommandModel(t *testing.T, line string) model {
+	t.Helper()
+	m := model{tree: NewJSONTree(), command: &shellCommand{line: line}}
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	m = updated.(model)
+	updated, _ = m.Update(m.Init()())
+	return updated.(model)
+}
+
+func TestCommand_Rerun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pods.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"a": {"b": 1}, "c": 2}`), 0o644))
+
+	m := newCommandModel(t, "cat "+path)
+	assert.Equal(t, Normal, m.mode)
+	assert.Equal(t, "json", m.opts.format)
+	assert.Equal(t, []string{"a", "c"}, m.tree.GetChildren(""))
+
+	// Folds are kept by path when the command runs again with r
+	m.tree.Collapse("a")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"a": {"b": 1}, "c": 3}`), 0o644))
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = updated.(model)
+	assert.Equal(t, "Running: cat "+path, m.statusBar)
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+
+	assert.True(t, m.tree.IsCollapsed("a"))
+	assert.Equal(t, Modified, m.tree.Changes[m.tree.lookup("c")])
+
+	// A failing command keeps the document and shows stderr
+	assert.NoError(t, os.Remove(path))
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = updated.(model)
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+
+	assert.Equal(t, Error, m.mode)
+	assert.Contains(t, m.statusBar, "Error: exit status 1: cat: ")
+	assert.NotNil(t, m.tree.Node("c"))
+}
+
+func TestCommand_Run(t *testing.T) {
+	m := newCommandModel(t, `echo '{"a": 1}'`)
+
+	// :run replaces the document, the format is detected again
+	m.commandBuffer = `run printf -- '---\nname: vj\n'`
+	updated, cmd := m.runCommand()
+	m = updated.(model)
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+
+	assert.Equal(t, "yaml", m.opts.format)
+	assert.Equal(t, "vj", mustGetValue(t, m.tree, "name"))
+}
+
+func TestCommand_FirstRunFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+	assert.NoError(t, os.WriteFile(path, []byte("not json"), 0o644))
+
+	// The error screen shows what the command printed on stderr, even
+	// when it exits with 0
+	m := newCommandModel(t, "cat "+path+"; echo 'token expired' >&2")
+	assert.Equal(t, ParseFailed, m.mode)
+	assert.NotNil(t, m.parseErr)
+	assert.Contains(t, m.View(), "token expired")
+	assert.Contains(t, m.View(), "r: run again")
+
+	// r runs the command again
+	assert.NoError(t, os.WriteFile(path, []byte(`{"a": 1}`), 0o644))
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = updated.(model)
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	assert.Equal(t, Normal, m.mode)
+	assert.Equal(t, json.Number("1"), mustGetValue(t, m.tree, "a"))
+	assert.Empty(t, m.tree.Changes[m.tree.lookup("a")])
+
+	// The empty tree stays after an error, the keys still work on it
+	m = newCommandModel(t, "exit 2")
+	assert.Equal(t, Error, m.mode)
+	assert.Contains(t, m.statusBar, "exit status 2")
+	assert.NotNil(t, m.tree)
+	for _, key := range []string{"esc", "j", "G", "n", "r"} {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if key == "esc" {
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		}
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	m.commandBuffer = "dup"
+	updated, _ = m.runCommand()
+	m = updated.(model)
+	assert.NotPanics(t, func() { m.View() })
+}
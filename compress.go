@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Magic bytes of the compressed formats that are read transparently
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+
+	// A bzip2 stream goes on with the block size, '1' to '9', then the
+	// magic of the first block, or of the end of the stream when it is
+	// empty
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// Extensions of compressed files, ignored to find the format of the content
+var compressionExts = []string{".gz", ".gzip", ".bz2"}
+
+// compression describes compressed input: its format and its size before
+// and after decompression
+type compression struct {
+	format       string
+	compressed   int64
+	decompressed int64
+}
+
+func (c compression) String() string {
+	if c.format == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %s → %s", c.format,
+		formatSize(c.compressed), formatSize(c.decompressed))
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decompress returns a reader of the decompressed content when r starts
+// with the magic bytes of gzip or bzip2, and the name of the format. Other
+// input is returned as is.
+func decompress(r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(bzip2Magic) + 1)
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		return zr, "gzip", err
+	case isBzip2(br, head):
+		return bzip2.NewReader(br), "bzip2", nil
+	}
+	return br, "", nil
+}
+
+// isBzip2 reports whether the input starts with a bzip2 header, so text
+// that starts with "BZh" is not read as bzip2. The rest of the header is
+// only read after "BZh" and a block size, a followed file may not have
+// it yet. The block magic is checked when the input is long enough.
+func isBzip2(br *bufio.Reader, head []byte) bool {
+	n := len(bzip2Magic)
+	if len(head) <= n || !bytes.HasPrefix(head, bzip2Magic) || head[n] < '1' || head[n] > '9' {
+		return false
+	}
+	head, _ = br.Peek(n + 1 + len(bzip2BlockMagic))
+	block := head[n+1:]
+	if len(block) < len(bzip2BlockMagic) {
+		return true
+	}
+	return bytes.Equal(block, bzip2BlockMagic) || bytes.Equal(block, bzip2EndMagic)
+}
+
+// readInput reads all of r, decompressing it if needed
+func readInput(r io.Reader) ([]byte, compression, error) {
+	counter := &countingReader{r: r}
+	dr, format, err := decompress(counter)
+	if err != nil {
+		return nil, compression{}, err
+	}
+
+	input, err := io.ReadAll(dr)
+	if err != nil {
+		return nil, compression{}, err
+	}
+
+	if format == "" {
+		return input, compression{}, nil
+	}
+	return input, compression{
+		format:       format,
+		compressed:   counter.n,
+		decompressed: int64(len(input)),
+	}, nil
+}
+
+// stripCompressionExt removes the extension of a compressed file, so
+// data.csv.gz is read as data.csv
+func stripCompressionExt(path string) string {
+	ext := filepath.Ext(path)
+	for _, compressed := range compressionExts {
+		if strings.EqualFold(ext, compressed) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// formatSize returns a size in bytes in a human readable unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	exp := 0
+	for value >= unit && exp < 5 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp-1])
+}
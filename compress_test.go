@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadInput_Gzip(t *testing.T) {
+	content := `{"id": 1, "name": "` + strings.Repeat("a", 1000) + `"}`
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(content))
+	zw.Close()
+	size := int64(buf.Len())
+
+	input, compressed, err := readInput(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(input))
+	assert.Equal(t, compression{format: "gzip", compressed: size,
+		decompressed: int64(len(content))}, compressed)
+}
+
+func TestReadInput_Bzip2(t *testing.T) {
+	// printf '{"a": 1}' | bzip2
+	input, compressed, err := readInput(bytes.NewReader(mustDecodeHex(t,
+		"425a6839314159265359d64d6a790000031980500020102000000a20002218021804e27d6e177245385090d64d6a79")))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a": 1}`, string(input))
+	assert.Equal(t, "bzip2 47 B → 8 B", compressed.String())
+}
+
+func TestReadInput_TextStartingWithBzip2Magic(t *testing.T) {
+	// Only "BZh" and a block size followed by the block magic is bzip2
+	for _, content := range []string{"BZh", "BZhello", "BZh9 is a tag", "BZh1 and more text"} {
+		input, compressed, err := readInput(strings.NewReader(content))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(input))
+		assert.Equal(t, "", compressed.String())
+	}
+
+	// printf '' | bzip2
+	input, compressed, err := readInput(bytes.NewReader(mustDecodeHex(t, "425a683917724538509000000000")))
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(input))
+	assert.Equal(t, "bzip2", compressed.format)
+}
+
+func TestReadInput_Uncompressed(t *testing.T) {
+	input, compressed, err := readInput(strings.NewReader(`{"a": 1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a": 1}`, string(input))
+	assert.Equal(t, "", compressed.String())
+}
+
+func TestStripCompressionExt(t *testing.T) {
+	assert.Equal(t, "data.csv", stripCompressionExt("data.csv.gz"))
+	assert.Equal(t, "dump.json", stripCompressionExt("dump.json.BZ2"))
+	assert.Equal(t, "dump.json", stripCompressionExt("dump.json"))
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KiB", formatSize(1536))
+	assert.Equal(t, "2.0 GiB", formatSize(2<<30))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// UTF-8 byte order mark
+var byteOrderMark = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseCSV reads CSV or TSV records as objects. The keys are the cells of
+// the header row, or column1, column2... when opts.noHeader is set. Cells
+// are strings, unless opts.inferTypes is set: then numbers and booleans
+// get their own types.
+//
+// On invalid input it returns a *ParseError and the rows read before the
+// error.
+func ParseCSV(src []byte, opts options) ([]interface{}, error) {
+	// Spreadsheet exports often start with a byte order mark
+	bom := 0
+	if bytes.HasPrefix(src, byteOrderMark) {
+		bom = len(byteOrderMark)
+	}
+
+	r := csv.NewReader(bytes.NewReader(src[bom:]))
+	r.Comma = opts.delimiter
+	if r.Comma == 0 {
+		r.Comma = ','
+		if opts.format == "tsv" {
+			r.Comma = '\t'
+		}
+	}
+	// TSV exports rarely quote their cells, a quote inside a cell like 12"
+	// is kept as is. A cell that starts with a quote is still read as a
+	// quoted cell.
+	r.LazyQuotes = r.Comma == '\t'
+	// Rows may have more or fewer cells than the header
+	r.FieldsPerRecord = -1
+
+	var header *csvHeader
+	rows := []interface{}{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, csvParseError(src, bom, err)
+		}
+
+		if header == nil && opts.noHeader {
+			header = newCSVHeader(nil)
+		} else if header == nil {
+			header = newCSVHeader(record)
+			continue
+		}
+
+		row := Object{}
+		for i, cell := range record {
+			key := header.key(i)
+
+			var value interface{} = cell
+			if opts.inferTypes {
+				value = inferCellType(cell)
+			}
+			row = append(row, Member{Key: key, Value: value})
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvHeader has the keys of the columns. Empty names are replaced by the
+// column name and repeated names get a number, so every cell of a row has
+// its own path.
+type csvHeader struct {
+	keys    []string
+	used    map[string]bool
+	repeats map[string]int
+}
+
+// newCSVHeader returns the keys of the header row
+func newCSVHeader(names []string) *csvHeader {
+	h := &csvHeader{used: make(map[string]bool), repeats: make(map[string]int)}
+	for _, name := range names {
+		h.add(name)
+	}
+	return h
+}
+
+// add adds the key of the next column
+func (h *csvHeader) add(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fmt.Sprintf("column%d", len(h.keys)+1)
+	}
+
+	// The numbered name may itself be in the header, like a,a,a_2
+	key := name
+	for h.used[key] {
+		h.repeats[name]++
+		key = fmt.Sprintf("%s_%d", name, h.repeats[name]+1)
+	}
+	h.used[key] = true
+	h.keys = append(h.keys, key)
+}
+
+// key returns the key of column i. The cells of a row longer than the
+// header get the column name, numbered when the header already has it.
+func (h *csvHeader) key(i int) string {
+	for len(h.keys) <= i {
+		h.add("")
+	}
+	return h.keys[i]
+}
+
+// inferCellType returns the cell as a number or a boolean when it is one.
+// Cells like 007 or 1,5 are not valid JSON numbers and stay strings.
+func inferCellType(cell string) interface{} {
+	switch strings.ToLower(cell) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	var number json.Number
+	if cell != "" && cell[0] != '"' &&
+		json.Unmarshal([]byte(cell), &number) == nil && string(number) == cell {
+		return number
+	}
+	return cell
+}
+
+// parseDelimiter reads the value of --delimiter: a single character, or
+// "\t" or "tab" for tabs
+func parseDelimiter(value string) (rune, error) {
+	switch value {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+
+	comma, size := utf8.DecodeRuneInString(value)
+	if size == 0 || size != len(value) || strings.ContainsRune("\"\r\n", comma) ||
+		comma == utf8.RuneError {
+		return 0, errors.New("the delimiter must be a single character, not " +
+			strconv.Quote(value))
+	}
+	return comma, nil
+}
+
+// csvParseError turns a csv error into a *ParseError
+func csvParseError(src []byte, bom int, err error) *ParseError {
+	parseErr := &ParseError{Err: err, Offset: int64(len(src))}
+
+	var csvErr *csv.ParseError
+	if errors.As(err, &csvErr) {
+		parseErr.Err = csvErr.Err
+		parseErr.Offset = lineOffset(src[bom:], csvErr.Line) +
+			int64(bom+max(csvErr.Column-1, 0))
+	}
+	return parseErr
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := "\xEF\xBB\xBFname,age,active\nAda,36,true\n\"Smith, J\",007,no\n"
+
+	tree, err := loadTree([]byte(input), options{format: "csv"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, ArrayType, tree.Node("").Type)
+	assert.Equal(t, []string{"0", "1"}, tree.GetChildren(""))
+	assert.Equal(t, []string{"0.name", "0.age", "0.active"}, tree.GetChildren("0"))
+
+	// Without type inference every cell is a string
+	assert.Equal(t, "36", mustGetValue(t, tree, "0.age"))
+	assert.Equal(t, "true", mustGetValue(t, tree, "0.active"))
+	assert.Equal(t, "Smith, J", mustGetValue(t, tree, "1.name"))
+}
+
+func TestParseCSV_InferTypes(t *testing.T) {
+	input := "id,price,paid,zip,note\n1,9.99,TRUE,007,\n"
+
+	rows, err := ParseCSV([]byte(input), options{inferTypes: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{Object{
+		{Key: "id", Value: json.Number("1")},
+		{Key: "price", Value: json.Number("9.99")},
+		{Key: "paid", Value: true},
+		// Leading zeros are kept
+		{Key: "zip", Value: "007"},
+		{Key: "note", Value: ""},
+	}}, rows)
+}
+
+func TestParseCSV_Options(t *testing.T) {
+	// Header-less, semicolon separated, with a row longer than the others
+	rows, err := ParseCSV([]byte("a;b\nc;d;e\n"),
+		options{delimiter: ';', noHeader: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		Object{{Key: "column1", Value: "a"}, {Key: "column2", Value: "b"}},
+		Object{{Key: "column1", Value: "c"}, {Key: "column2", Value: "d"},
+			{Key: "column3", Value: "e"}},
+	}, rows)
+
+	// TSV cells may contain quotes
+	rows, err = ParseCSV([]byte("size\tname\n12\"\tscreen\n"), options{format: "tsv"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		Object{{Key: "size", Value: `12"`}, {Key: "name", Value: "screen"}},
+	}, rows)
+}
+
+func TestCSVHeader(t *testing.T) {
+	assert.Equal(t, []string{"id", "column2", "id_2", "name"},
+		newCSVHeader([]string{"id", "", "id", " name "}).keys)
+	assert.Equal(t, []string{"a", "a_2", "a_2_2", "a_3"},
+		newCSVHeader([]string{"a", "a", "a_2", "a"}).keys)
+
+	// The cells after the header do not take a name of the header
+	rows, err := ParseCSV([]byte("column3,column4\n1,2,3,4\n"), options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		Object{{Key: "column3", Value: "1"}, {Key: "column4", Value: "2"},
+			{Key: "column3_2", Value: "3"}, {Key: "column4_2", Value: "4"}},
+	}, rows)
+}
+
+func TestParseCSV_Error(t *testing.T) {
+	input := "a,b\n1,2\n3,\"4\n"
+
+	tree, err := loadTree([]byte(input), options{format: "csv"})
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	parseErr.Locate([]byte(input))
+	// The quoted cell is still open at the end of the input
+	assert.Equal(t, int64(len(input)), parseErr.Offset)
+
+	// The rows before the error can be browsed
+	assert.Equal(t, "2", mustGetValue(t, tree, "0.b"))
+}
+
+func TestParseDelimiter(t *testing.T) {
+	comma, err := parseDelimiter(";")
+	assert.NoError(t, err)
+	assert.Equal(t, ';', comma)
+
+	comma, err = parseDelimiter("tab")
+	assert.NoError(t, err)
+	assert.Equal(t, '\t', comma)
+
+	_, err = parseDelimiter(";;")
+	assert.Error(t, err)
+	_, err = parseDelimiter(`"`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+)
+
+// stringDecoder recognizes and decodes one kind of encoded string. The
+// value is a string, bytes, or an object or array shown as a subtree.
+type stringDecoder struct {
+	name   string
+	decode func(s string) (interface{}, bool)
+}
+
+// A string that holds JSON is decoded with l, like a node is unfolded
+var jsonDecoder = stringDecoder{"json", decodeJSONString}
+
+// Decoders tried in order on a string decoded with d, the first one that
+// recognizes it wins. Hex goes before base64, since hex digits are valid
+// base64 too.
+var stringDecoders = []stringDecoder{
+	jsonDecoder,
+	{"jwt", decodeJWT},
+	{"url", decodeURL},
+	{"hex", decodeHex},
+	{"base64", decodeBase64},
+}
+
+// decodeString returns the value of s decoded by the first decoder that
+// recognizes it, and the name of its encoding
+func decodeString(s string, decoders []stringDecoder) (interface{}, string, bool) {
+	for _, decoder := range decoders {
+		if value, ok := decoder.decode(s); ok {
+			return value, decoder.name, true
+		}
+	}
+	return nil, "", false
+}
+
+// decodeJSONString returns the value of a string that holds a JSON object
+// or array, like a payload that was encoded twice
+func decodeJSONString(s string) (interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+		return nil, false
+	}
+
+	value, err := ParseJSON(strings.NewReader(s))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// DecodeString replaces an encoded string node with the value it
+// encodes: a subtree for JSON, JWTs and URLs, or the decoded text or
+// bytes. The node keeps its path and is noted as decoded; RestoreString
+// turns it back into the string.
+func (jt *JSONTree) DecodeString(path string) bool {
+	id := jt.lookup(path)
+	return id != noNode && jt.decodeString(id, stringDecoders)
+}
+
+func (jt *JSONTree) decodeString(id NodeID, decoders []stringDecoder) bool {
+	node := &jt.nodes[id]
+	// A decoded string is not decoded again, it could not be restored
+	if node.Type != StringType || node.Encoded != "" {
+		return false
+	}
+	s := nodeValueToString(node)
+	value, name, ok := decodeString(s, decoders)
+	if !ok {
+		return false
+	}
+
+	node.Value = leafValue(value)
+	node.Type = getNodeType(value)
+	node.Encoded = s
+	node.Note = strings.TrimSpace(node.Note + " decoded " + name)
+	jt.spliceLines(jt.LineNumber(id)+1, func() { jt.buildChildren(id, value) })
+	jt.showSpliced(id)
+	return true
+}
+
+// RestoreString turns a decoded node back into its string, removing the
+// subtree built by DecodeString
+func (jt *JSONTree) RestoreString(path string) bool {
+	id := jt.lookup(path)
+	return id != noNode && jt.restoreString(id)
+}
+
+func (jt *JSONTree) restoreString(id NodeID) bool {
+	node := &jt.nodes[id]
+	if node.Encoded == "" {
+		return false
+	}
+
+	jt.removeLinesInside(id)
+	jt.removeDescendants(id)
+	jt.collapsed[id] = false
+
+	node.Value = node.Encoded
+	node.Type = StringType
+	node.Encoded = ""
+	if i := strings.LastIndex(node.Note, "decoded "); i >= 0 {
+		node.Note = strings.TrimSpace(node.Note[:i])
+	}
+	return true
+}
+
+// DecodedPaths returns the paths of the decoded strings, parents first,
+// so they can be decoded again in a reloaded tree
+func (jt *JSONTree) DecodedPaths() []string {
+	var paths []string
+	jt.walk(func(id NodeID) {
+		if jt.nodes[id].Encoded != "" {
+			paths = append(paths, jt.Path(id))
+		}
+	})
+	return paths
+}
+
+// removeDescendants detaches the nodes under id and clears their state.
+// Their IDs are not used again.
+func (jt *JSONTree) removeDescendants(id NodeID) {
+	for child := jt.firstChild[id]; child != noNode; {
+		next := jt.nextSibling[child]
+		jt.removeDescendants(child)
+		jt.nodes[child] = Node{ID: child}
+		jt.parent[child], jt.nextSibling[child] = noNode, noNode
+		jt.collapsed[child] = false
+		delete(jt.Changes, child)
+		delete(jt.index, child)
+		jt.removed++
+		child = next
+	}
+	jt.firstChild[id], jt.lastChild[id] = noNode, noNode
+	delete(jt.index, id)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeString(t *testing.T) {
+	tree := mustLoadTree(t,
+		`{"body": "{\"id\": 1, \"tags\": [\"a\"]}", "after": true, "text": "{not json"}`)
+
+	assert.False(t, tree.DecodeString("text"))
+	assert.False(t, tree.DecodeString("after"))
+
+	assert.True(t, tree.DecodeString("body"))
+	node := tree.Node("body")
+	assert.Equal(t, ObjectType, node.Type)
+	assert.Equal(t, "decoded json", node.Note)
+	assert.Equal(t, []string{"body.id", "body.tags"}, tree.GetChildren("body"))
+	assert.Equal(t, json.Number("1"), mustGetValue(t, tree, "body.id"))
+	assert.Equal(t, "a", mustGetValue(t, tree, "body.tags[0]"))
+
+	// The lines after the subtree move down
+	assert.Equal(t, 1, tree.LineNumber(node.ID))
+	assert.Equal(t, 6, tree.ClosingLineNumber(node.ID))
+	assert.Equal(t, 7, tree.LineNumber(tree.Node("after").ID))
+	after, _ := tree.GetNodeAtLine(7)
+	assert.Equal(t, tree.Node("after"), after)
+	assertLines(t, tree)
+
+	assert.True(t, tree.RestoreString("body"))
+	assert.Equal(t, StringType, node.Type)
+	assert.Equal(t, `{"id": 1, "tags": ["a"]}`, node.Value)
+	assert.Equal(t, "", node.Note)
+	assert.Nil(t, tree.Node("body.id"))
+	assert.Equal(t, 2, tree.LineNumber(tree.Node("after").ID))
+	assertLines(t, tree)
+	assert.False(t, tree.RestoreString("body"))
+}
+
+func TestDecodeString_RootAndRecords(t *testing.T) {
+	tree := mustLoadTree(t, `"[1, 2]"`)
+	assert.True(t, tree.DecodeString(""))
+	assert.Equal(t, ArrayType, tree.Node("").Type)
+	assert.Equal(t, []string{"0", "1"}, tree.GetChildren(""))
+
+	tree, err := loadTree([]byte(`{"a": 1}`+"\n"+`"{\"b\": 2}"`), options{})
+	assert.NoError(t, err)
+	assert.True(t, tree.DecodeString("1"))
+	assert.Equal(t, json.Number("2"), mustGetValue(t, tree, "1.b"))
+	node, _ := tree.GetNodeAtLine(4)
+	assert.Equal(t, tree.Node("1.b"), node)
+}
+
+func TestDecodeString_Keys(t *testing.T) {
+	m := newReloadModel(t, `{"body": "{\"id\": 1}", "n": 2}`)
+	m.cursorY = 1
+
+	// l decodes the string, h turns it back into the string
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updated.(model)
+	assert.Equal(t, 6, m.visibleLines2.Len())
+	assert.Equal(t, "body.id", m.tree.Path(m.visibleLines2.linesOnScreen[2].NodeID))
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	m = updated.(model)
+	assert.Equal(t, 4, m.visibleLines2.Len())
+	assert.Equal(t, StringType, m.tree.Node("body").Type)
+}
+
+func TestDecodeString_GuessedKey(t *testing.T) {
+	m := newReloadModel(t, `{"hash": "68656c6c6f", "n": 2}`)
+	m.cursorY = 1
+
+	// l only decodes JSON, the other encodings wait for d
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updated.(model)
+	assert.Equal(t, StringType, m.tree.Node("hash").Type)
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = updated.(model)
+	assert.Equal(t, "hello", mustGetValue(t, m.tree, "hash"))
+	assert.Equal(t, "decoded hex", m.tree.Node("hash").Note)
+
+	m.cursorY = 2
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = updated.(model)
+	assert.Equal(t, "No encoding recognized", m.statusBar)
+}
+
+func TestApplyReload_KeepsDecodedStrings(t *testing.T) {
+	m := newReloadModel(t, `{"body": "{\"id\": 1, \"obj\": {\"x\": 1}}"}`)
+	m.tree.DecodeString("body")
+	m.tree.Collapse("body.obj")
+
+	tree := mustLoadTree(t, `{"body": "{\"id\": 2, \"obj\": {\"x\": 1}}"}`)
+	updated, _ := m.applyReload(reloadMsg{tree: tree})
+	m = updated.(model)
+
+	assert.Equal(t, json.Number("2"), mustGetValue(t, m.tree, "body.id"))
+	assert.True(t, m.tree.IsCollapsed("body.obj"))
+	assert.Equal(t, Modified, m.tree.Changes[m.tree.lookup("body.id")])
+	assert.Equal(t, Unchanged, m.tree.Changes[m.tree.lookup("body")])
+}
+
+func TestDecodeStringValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		decoder  string
+		expected interface{}
+	}{
+		{"base64 text", "aGVsbG8gd29ybGQ=", "base64", "hello world"},
+		// Padding is not an empty query parameter
+		{"base64 padding", "aGVsbG8gdGhlcmU=", "base64", "hello there"},
+		{"base64 JSON", "eyJpZCI6IDd9", "base64",
+			Object{{Key: "id", Value: json.Number("7")}}},
+		{"base64 bytes", "AAECAwQFBgcICQoLDA0ODxAREhM=", "base64",
+			Bytes{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}},
+		{"hex text", "68656c6c6f", "hex", "hello"},
+		{"hex bytes", "0xdeadbeef", "hex", Bytes{0xde, 0xad, 0xbe, 0xef}},
+		{"percent-encoding", "caf%C3%A9%2Fbar", "url", "café/bar"},
+		{"query string", "?q=a+b&tag=x&tag=y", "url", Object{
+			{Key: "q", Value: "a b"},
+			{Key: "tag", Value: []interface{}{"x", "y"}},
+		}},
+		{"URL", "https://api.example.com/v1/items?id=3#top", "url", Object{
+			{Key: "scheme", Value: "https"},
+			{Key: "host", Value: "api.example.com"},
+			{Key: "path", Value: "/v1/items"},
+			{Key: "query", Value: Object{{Key: "id", Value: "3"}}},
+			{Key: "fragment", Value: "top"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, decoder, ok := decodeString(tt.input, stringDecoders)
+			assert.True(t, ok)
+			assert.Equal(t, tt.decoder, decoder)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+
+	// Words and short numbers are not taken for hex or base64
+	for _, input := range []string{"test", "1234", "2024", "aGk=", "hello world"} {
+		_, _, ok := decodeString(input, stringDecoders)
+		assert.False(t, ok, input)
+	}
+}
+
+func TestDecodeString_JWT(t *testing.T) {
+	token := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+		"eyJzdWIiOiI0MiIsImlhdCI6MTUxNjIzOTAyMn0.AQID"
+	tree := mustLoadTree(t, `{"token": "`+token+`"}`)
+
+	assert.True(t, tree.DecodeString("token"))
+	assert.Equal(t, "decoded jwt", tree.Node("token").Note)
+	assert.Equal(t, "HS256", mustGetValue(t, tree, "token.header.alg"))
+	assert.Equal(t, "42", mustGetValue(t, tree, "token.claims.sub"))
+
+	// Times are shown as dates, with the number as a note
+	assert.Equal(t, DateTime("2018-01-18T01:30:22Z"), mustGetValue(t, tree, "token.claims.iat"))
+	assert.Equal(t, "1516239022", tree.Node("token.claims.iat").Note)
+	assert.Equal(t, Bytes{1, 2, 3}, mustGetValue(t, tree, "token.signature"))
+
+	assert.True(t, tree.RestoreString("token"))
+	assert.Equal(t, token, mustGetValue(t, tree, "token"))
+}
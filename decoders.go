@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JWT claims that hold a time in seconds since the Unix epoch
+var jwtTimeClaims = []string{"exp", "iat", "nbf", "auth_time"}
+
+// Hex and base64 strings shorter than this are more likely words or
+// numbers than encoded text or bytes, unless they decode to JSON
+const (
+	minHexBytesLength    = 8
+	minBase64BytesLength = 16
+)
+
+var percentEscape = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+
+// decodeJWT decodes a JSON Web Token into its header, its claims and its
+// signature. The time claims are shown as dates; the signature is not
+// verified.
+func decodeJWT(s string) (interface{}, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	var sections [2]Object
+	for i, part := range parts[:2] {
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+		if err != nil {
+			return nil, false
+		}
+		value, err := ParseJSON(strings.NewReader(string(data)))
+		obj, ok := value.(Object)
+		if err != nil || !ok {
+			return nil, false
+		}
+		sections[i] = obj
+	}
+
+	claims := sections[1]
+	for i, member := range claims {
+		if !slices.Contains(jwtTimeClaims, member.Key) {
+			continue
+		}
+		if date, ok := unixDate(member.Value); ok {
+			claims[i].Value = Annotated{Value: DateTime(date), Note: fmt.Sprint(member.Value)}
+		}
+	}
+
+	var signature interface{} = parts[2]
+	if data, err := base64.RawURLEncoding.DecodeString(parts[2]); err == nil {
+		signature = Bytes(data)
+	}
+
+	return Object{
+		{Key: "header", Value: sections[0]},
+		{Key: "claims", Value: claims},
+		{Key: "signature", Value: signature},
+	}, true
+}
+
+// unixDate returns the date of a number of seconds since the Unix epoch
+func unixDate(value interface{}) (string, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return "", false
+	}
+	seconds, err := number.Int64()
+	if err != nil {
+		return "", false
+	}
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339), true
+}
+
+// decodeURL decodes a URL into its parts, a query string into its
+// parameters, or a percent-encoded string into its text
+func decodeURL(s string) (interface{}, bool) {
+	if strings.ContainsAny(s, " \t\n") {
+		return nil, false
+	}
+
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+		var parts Object
+		add := func(key string, value interface{}) {
+			if value != "" && value != nil {
+				parts = append(parts, Member{Key: key, Value: value})
+			}
+		}
+		add("scheme", u.Scheme)
+		add("user", u.User.Username())
+		add("host", u.Host)
+		add("path", u.Path)
+		if query, ok := decodeQuery(u.RawQuery); ok {
+			add("query", query)
+		}
+		add("fragment", u.Fragment)
+		return parts, true
+	}
+
+	if query, ok := decodeQuery(strings.TrimPrefix(s, "?")); ok {
+		return query, true
+	}
+
+	if percentEscape.MatchString(s) {
+		if text, err := url.PathUnescape(s); err == nil {
+			return text, true
+		}
+	}
+	return nil, false
+}
+
+// decodeQuery decodes the parameters of a query string in their order, a
+// parameter that is repeated becomes an array of its values
+func decodeQuery(query string) (interface{}, bool) {
+	if !strings.Contains(query, "=") {
+		return nil, false
+	}
+	// A single parameter without a value is more likely base64 padding
+	if strings.HasSuffix(query, "=") && !strings.Contains(query, "&") {
+		return nil, false
+	}
+
+	var params Object
+	index := make(map[string]int)
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		if strings.Contains(rawValue, "=") {
+			return nil, false
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil || key == "" {
+			return nil, false
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, false
+		}
+
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(params)
+			params = append(params, Member{Key: key, Value: value})
+			continue
+		}
+		if values, ok := params[i].Value.([]interface{}); ok {
+			params[i].Value = append(values, value)
+		} else {
+			params[i].Value = []interface{}{params[i].Value, value}
+		}
+	}
+	return params, len(params) > 0
+}
+
+// decodeHex decodes a string of hex digits, with an optional 0x prefix
+func decodeHex(s string) (interface{}, bool) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	data, err := hex.DecodeString(digits)
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	return decodedBytes(data, len(digits) >= minHexBytesLength)
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without
+// padding
+func decodeBase64(s string) (interface{}, bool) {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding, base64.URLEncoding,
+		base64.RawStdEncoding, base64.RawURLEncoding,
+	}
+	for _, encoding := range encodings {
+		if data, err := encoding.DecodeString(s); err == nil && len(data) > 0 {
+			return decodedBytes(data, len(s) >= minBase64BytesLength)
+		}
+	}
+	return nil, false
+}
+
+// decodedBytes returns decoded bytes as the JSON value, the text or the
+// bytes they hold. Only JSON is returned when the encoded string is short.
+func decodedBytes(data []byte, long bool) (interface{}, bool) {
+	text := isText(data)
+	if value, ok := decodeJSONString(string(data)); ok && text {
+		return value, true
+	}
+	if !long {
+		return nil, false
+	}
+	if !text {
+		return Bytes(data), true
+	}
+	return string(data), true
+}
+
+// isText reports whether data is UTF-8 text without control characters
+// other than whitespace
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
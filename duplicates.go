@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of duplicate keys named in the summary shown on load
+const duplicateSummaryLength = 3
+
+// duplicateKeys returns the plain paths of the keys that appear more than
+// once in their object, in document order, with their number of
+// occurrences
+func (jt *JSONTree) duplicateKeys() ([]string, map[string]int) {
+	var keys []string
+	counts := make(map[string]int)
+
+	for _, id := range jt.Duplicates {
+		if jt.parent[id] == noNode {
+			// Removed with a decoded string
+			continue
+		}
+		// The last occurrence has the path of the key, without #n
+		plain := buildChildPath(jt.Path(jt.parent[id]), jt.nodes[id].Key, false)
+		if counts[plain] == 0 {
+			keys = append(keys, plain)
+		}
+		counts[plain]++
+	}
+	return keys, counts
+}
+
+// summarizeDuplicates describes the duplicate keys of the tree, or returns
+// "" when there are none
+func summarizeDuplicates(tree *JSONTree) string {
+	if tree == nil {
+		return ""
+	}
+	keys, counts := tree.duplicateKeys()
+	if len(keys) == 0 {
+		return ""
+	}
+
+	var names []string
+	for i, key := range keys {
+		if i == duplicateSummaryLength {
+			names = append(names, "…")
+			break
+		}
+		names = append(names, fmt.Sprintf(".%s (%d×)", key, counts[key]))
+	}
+
+	noun := "key"
+	if len(keys) > 1 {
+		noun = "keys"
+	}
+	return warningStyle.Render(fmt.Sprintf("⚠ %d duplicate %s: %s",
+		len(keys), noun, strings.Join(names, ", ")))
+}
+
+// jumpToNextDuplicate moves the cursor to the next member with a
+// duplicate key after the cursor, wrapping around to the first one.
+// Folded parents are unfolded to show it.
+func (m *model) jumpToNextDuplicate() bool {
+	current := -1
+	if node, exists := m.nodeAtCursor(); exists {
+		current = m.tree.LineNumber(node.ID)
+	}
+
+	var first, next *Node
+	firstLine, nextLine := 0, 0
+	for _, id := range m.tree.Duplicates {
+		if m.tree.parent[id] == noNode {
+			continue
+		}
+		node := &m.tree.nodes[id]
+		line := m.tree.LineNumber(id)
+		if first == nil || line < firstLine {
+			first, firstLine = node, line
+		}
+		if line > current && (next == nil || line < nextLine) {
+			next, nextLine = node, line
+		}
+	}
+	if next == nil {
+		next = first
+	}
+	if next == nil {
+		return false
+	}
+
+	for parent := m.tree.Parent(next.ID); parent != noNode; parent = m.tree.Parent(parent) {
+		m.tree.setCollapsed(parent, false)
+	}
+	m.visibleLines2.UpdateContent2(m.tree)
+
+	if virtualLine, found := m.findVirtualLine(next.ID); found {
+		m.cursorY = virtualLine
+	}
+	m.currentPath = m.tree.displayPath(next)
+	m.ScrollDown()
+	m.ScrollUp()
+	m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
+		m.visibleLines2.total)
+	return true
+}
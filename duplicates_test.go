@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTree_DuplicateKeys(t *testing.T) {
+	tree := mustLoadTree(t, `{"a": 1, "b": {"c": 1, "c": 2}, "a": 2, "a": {"x": 3}}`)
+
+	// Every occurrence is kept, the last one has the plain path
+	assert.Equal(t, []string{"a#1", "b", "a#2", "a"}, tree.GetChildren(""))
+	assert.Equal(t, []string{"b.c#1", "b.c"}, tree.GetChildren("b"))
+	assert.Equal(t, json.Number("1"), mustGetValue(t, tree, "a#1"))
+	assert.Equal(t, json.Number("2"), mustGetValue(t, tree, "a#2"))
+	assert.Equal(t, json.Number("3"), mustGetValue(t, tree, "a.x"))
+	assert.Equal(t, "a", tree.Node("a#1").Key)
+
+	assert.True(t, tree.Node("a#1").Duplicate)
+	assert.True(t, tree.Node("b.c").Duplicate)
+	assert.False(t, tree.Node("b").Duplicate)
+	var duplicates []string
+	for _, id := range tree.Duplicates {
+		duplicates = append(duplicates, tree.Path(id))
+	}
+	assert.Equal(t, []string{"a#1", "b.c#1", "b.c", "a#2", "a"}, duplicates)
+
+	// Each occurrence has its own line
+	for _, path := range tree.GetAllPaths() {
+		node, _ := tree.GetNodeAtLine(tree.LineNumber(tree.Node(path).ID))
+		assert.Equal(t, path, tree.Path(node.ID))
+	}
+}
+
+func TestDuplicateKeys_Rendering(t *testing.T) {
+	currentTheme = themes["nocolor"]
+	tree := mustLoadTree(t, `{"a": 1, "a": {"b": true}}`)
+
+	var lines []string
+	for _, line := range tree.PrintAsJSON2() {
+		lines = append(lines, RenderLine(line, false))
+	}
+
+	expected := "{\n" +
+		"  \"a\": 1, ⚠ duplicate key\n" +
+		"  \"a\": { ⚠ duplicate key\n" +
+		"    \"b\": true\n" +
+		"  }\n" +
+		"}"
+	assert.Equal(t, expected, strings.Join(lines, "\n"))
+}
+
+func TestSummarizeDuplicates(t *testing.T) {
+	assert.Equal(t, "", summarizeDuplicates(mustLoadTree(t, `{"a": 1}`)))
+
+	tree := mustLoadTree(t, `{"a": 1, "a": 2, "a": 3, "b": [{"c": 1, "c": 2}]}`)
+	assert.Equal(t, "⚠ 2 duplicate keys: .a (3×), .b[0].c (2×)", summarizeDuplicates(tree))
+
+	tree = mustLoadTree(t, `{"a": 1, "a": 2, "b": 1, "b": 2, "c": 1, "c": 2, "d": 1, "d": 2}`)
+	assert.Equal(t, "⚠ 4 duplicate keys: .a (2×), .b (2×), .c (2×), …", summarizeDuplicates(tree))
+}
+
+func TestJumpToNextDuplicate(t *testing.T) {
+	m := newReloadModel(t, `{"x": 0, "a": 1, "o": {"a": 2, "a": 3}, "a": 4}`)
+	m.tree.Collapse("o")
+
+	command := func() {
+		m.commandBuffer = "dup"
+		updated, _ := m.runCommand()
+		m = updated.(model)
+	}
+
+	// The folded object is unfolded to show its duplicates
+	var paths []string
+	for range 5 {
+		command()
+		node, _ := m.nodeAtCursor()
+		paths = append(paths, m.tree.Path(node.ID))
+	}
+	assert.Equal(t, []string{"a#1", "o.a#1", "o.a", "a", "a#1"}, paths)
+	assert.False(t, m.tree.IsCollapsed("o"))
+	assert.Equal(t, ".a#1", m.statusBar)
+
+	m = newReloadModel(t, `{"a": 1}`)
+	command()
+	assert.Equal(t, Error, m.mode)
+
+	// Paths with #n can be used on the command line
+	m = newReloadModel(t, `{"a": 1, "a": 2}`)
+	m.commandBuffer = ".a#1"
+	updated, _ := m.runCommand()
+	m = updated.(model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	assert.Equal(t, ".a#1", m.statusBar)
+}
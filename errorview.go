@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Number of source lines shown before and after the line with the error
+const errorContextLines = 3
+
+func (m model) UpdateParseErrorMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "esc", "ctrl+c":
+		return m, tea.Quit
+
+	case "enter":
+		// Browse the part of the document parsed before the error
+		if m.canBrowse() {
+			m.mode = Normal
+			m.statusBar = errorStyle.Render(fmt.Sprintf(
+				"Parse error at line %d, column %d (:error to show it)",
+				m.parseErr.Line, m.parseErr.Column))
+		}
+
+	case "r":
+		// The output of the command may be valid the next time
+		if m.command != nil {
+			return m.reload()
+		}
+	}
+
+	return m, nil
+}
+
+// canBrowse reports whether there is a tree with at least one line
+func (m model) canBrowse() bool {
+	return m.tree != nil && m.tree.VisibleLen() > 0
+}
+
+// RenderParseError renders the parse error screen: the error message,
+// its position and the source lines around it with the bad token
+// highlighted.
+func (m model) RenderParseError() string {
+	e := m.parseErr
+	s := errorStyle.Render("Error: "+e.Err.Error()) + "\n"
+	s += fmt.Sprintf("line %d, column %d (byte %d)\n\n", e.Line, e.Column, e.Offset)
+
+	// Binary input has no lines to show
+	if !utf8.Valid(m.source) {
+		return s + m.commandStderr() + m.parseErrorKeys()
+	}
+
+	lines := bytes.Split(m.source, []byte("\n"))
+	first := max(0, e.Line-1-errorContextLines)
+	last := min(len(lines)-1, e.Line-1+errorContextLines)
+
+	// Room left for the source after the line numbers column
+	width := max(m.width-7, 20)
+
+	for i := first; i <= last; i++ {
+		text := []rune(strings.TrimSuffix(string(lines[i]), "\r"))
+
+		if i != e.Line-1 {
+			text, _ = clipLine(text, 0, width)
+			s += lineNumbersCol.Render(strconv.Itoa(i+1)) + "  " +
+				string(text) + "\n"
+			continue
+		}
+
+		col := min(e.Column-1, len(text))
+		text, col = clipLine(text, col, width)
+		end := tokenEnd(text, col)
+
+		atEOF := e.Offset >= int64(len(m.source))
+		s += lineNumbersCol.Render(strconv.Itoa(i+1)) + errorStyle.Render(" >") +
+			string(text[:col]) + highlightToken(text[col:end], atEOF) +
+			string(text[end:]) + "\n"
+	}
+
+	return s + m.commandStderr() + m.parseErrorKeys()
+}
+
+// commandStderr returns the end of the stderr of the command, which
+// often says why its output is not valid
+func (m model) commandStderr() string {
+	if m.command == nil || m.stderr == "" {
+		return ""
+	}
+	return "\n" + noteStyle.Render("stderr:") + "\n" + m.stderr + "\n"
+}
+
+// parseErrorKeys returns the help line of the parse error screen
+func (m model) parseErrorKeys() string {
+	var keys []string
+	if m.canBrowse() {
+		keys = append(keys, "enter: browse the valid part")
+	}
+	if m.command != nil {
+		keys = append(keys, "r: run again")
+	}
+	return "\n" + strings.Join(append(keys, "q: quit"), "    ")
+}
+
+// clipLine cuts a long line (minified JSON is often a single line) to
+// width runes, keeping col visible. It returns the new column of col.
+func clipLine(text []rune, col int, width int) ([]rune, int) {
+	if len(text) <= width {
+		return text, col
+	}
+
+	start := max(0, col-width/2)
+	end := min(len(text), start+width)
+	return text[start:end], col - start
+}
+
+// tokenEnd returns the index right after the token that starts at col
+func tokenEnd(text []rune, col int) int {
+	if col >= len(text) {
+		return len(text)
+	}
+
+	if strings.ContainsRune("{}[],:", text[col]) {
+		return col + 1
+	}
+
+	end := col
+	for end < len(text) && !strings.ContainsRune("{}[],: \t", text[end]) {
+		end++
+	}
+	return end
+}
+
+func highlightToken(token []rune, atEOF bool) string {
+	style := errorStyle.Reverse(true)
+
+	// Nothing to highlight when the input ended or the bad
+	// character is the line break, so show a marker instead
+	if len(token) == 0 {
+		if atEOF {
+			return style.Render("EOF")
+		}
+		return style.Render("↵")
+	}
+
+	return style.Render(string(token))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// How long a request may take, including reading the body
+const requestTimeout = 30 * time.Second
+
+// httpRequest is the request that loads the document from a URL, made
+// again by :reload
+type httpRequest struct {
+	url      string
+	method   string // GET, or POST when there is a body
+	header   http.Header
+	bodyFile string // file with the request body
+}
+
+// httpResponse is the metadata of the response shown in the status bar
+type httpResponse struct {
+	status      string // 200 OK
+	contentType string
+}
+
+func (r httpResponse) String() string {
+	return strings.TrimSpace(r.status + " " + r.contentType)
+}
+
+// Media types of the formats, for responses read without --format. Types
+// that end with +json, like application/problem+json, are JSON too.
+var mediaTypeFormats = map[string]string{
+	"application/json":          "json",
+	"application/x-ndjson":      "json",
+	"application/jsonl":         "json",
+	"application/json5":         "json5",
+	"application/yaml":          "yaml",
+	"application/x-yaml":        "yaml",
+	"text/yaml":                 "yaml",
+	"application/toml":          "toml",
+	"text/csv":                  "csv",
+	"text/tab-separated-values": "tsv",
+	"application/cbor":          "cbor",
+	"application/msgpack":       "msgpack",
+	"application/x-msgpack":     "msgpack",
+	"application/vnd.msgpack":   "msgpack",
+}
+
+// isURL reports whether the argument is an HTTP or HTTPS URL instead of
+// a file
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
+
+// parseHeader adds a "Name: value" header to h
+func parseHeader(h http.Header, header string) error {
+	name, value, found := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+	}
+	h.Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+// fetch makes the request and reads the body of the response, which is
+// decompressed if needed. Responses with an error status are returned as
+// well, their body often explains the error.
+func fetch(req httpRequest) ([]byte, compression, httpResponse, error) {
+	var body io.Reader
+	method := req.method
+	if req.bodyFile != "" {
+		data, err := os.ReadFile(req.bodyFile)
+		if err != nil {
+			return nil, compression{}, httpResponse{}, err
+		}
+		body = bytes.NewReader(data)
+		if method == "" {
+			method = http.MethodPost
+		}
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	r, err := http.NewRequest(method, req.url, body)
+	if err != nil {
+		return nil, compression{}, httpResponse{}, err
+	}
+	for name, values := range req.header {
+		r.Header[name] = values
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, compression{}, httpResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	response := httpResponse{
+		status:      resp.Status,
+		contentType: resp.Header.Get("Content-Type"),
+	}
+	input, compressed, err := readInput(resp.Body)
+	return input, compressed, response, err
+}
+
+// detectResponseFormat returns the format of a response from its content
+// type, or else from the extension of the URL path and the content
+func detectResponseFormat(req httpRequest, response httpResponse, input []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(response.contentType)
+	if format, found := mediaTypeFormats[mediaType]; found {
+		return format
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		return "json"
+	}
+
+	path := ""
+	if u, err := url.Parse(req.url); err == nil {
+		path = u.Path
+	}
+	return detectFormat(path, input)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprintf(w, `{"method": %q, "auth": %q, "body": %q}`,
+			r.Method, r.Header.Get("Authorization"), body)
+	}))
+	defer server.Close()
+
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	assert.NoError(t, os.WriteFile(bodyFile, []byte(`{"q": 1}`), 0o644))
+
+	parsed, err := parseArgs([]string{server.URL + "/items",
+		"-H", "Authorization: Bearer abc", "--body-file", bodyFile})
+	assert.NoError(t, err)
+	req := parsed.request
+	req.url = parsed.files[0]
+
+	input, _, response, err := fetch(req)
+	assert.NoError(t, err)
+
+	// The body of an error response is shown too
+	assert.Equal(t, "418 I'm a teapot application/problem+json", response.String())
+	assert.Equal(t, "json", detectResponseFormat(req, response, input))
+
+	tree, err := loadTree(input, options{format: "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", mustGetValue(t, tree, "method"))
+	assert.Equal(t, "Bearer abc", mustGetValue(t, tree, "auth"))
+	assert.Equal(t, `{"q": 1}`, mustGetValue(t, tree, "body"))
+
+	// The method can be given
+	req.method = "PUT"
+	input, _, _, err = fetch(req)
+	assert.NoError(t, err)
+	assert.Contains(t, string(input), `"method": "PUT"`)
+}
+
+func TestDetectResponseFormat(t *testing.T) {
+	yaml := httpResponse{contentType: "application/yaml; charset=utf-8"}
+	assert.Equal(t, "yaml", detectResponseFormat(httpRequest{}, yaml, nil))
+
+	// Without a known content type, the URL path is used like a file name
+	text := httpResponse{contentType: "text/plain"}
+	req := httpRequest{url: "https://example.com/Cargo.toml?raw=1"}
+	assert.Equal(t, "toml", detectResponseFormat(req, text, nil))
+}
+
+func TestParseArgs_Header(t *testing.T) {
+	_, err := parseArgs([]string{"-H", "no colon", "https://example.com"})
+	assert.EqualError(t, err, `invalid header "no colon", expected "Name: value"`)
+}
+
+func TestReloadURL(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"count": %d, "same": true}`, count)
+	}))
+	defer server.Close()
+
+	req := httpRequest{url: server.URL}
+	input, _, response, err := fetch(req)
+	assert.NoError(t, err)
+
+	m := newReloadModel(t, string(input))
+	m.request, m.response = &req, response
+	m.opts.format = "json"
+	assert.Contains(t, m.UpdateStatusBar(), "200 OK application/json · json")
+
+	m.commandBuffer = "reload"
+	updated, cmd := m.runCommand()
+	m = updated.(model)
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+
+	assert.Equal(t, 2, count)
+	assert.Equal(t, Modified, m.tree.Changes[m.tree.lookup("count")])
+	assert.Equal(t, Unchanged, m.tree.Changes[m.tree.lookup("same")])
+	assert.Equal(t, "Reloaded: 0 added, 1 changed, 0 with removed children", m.statusBar)
+
+	// A failed request keeps the tree
+	server.Close()
+	m.commandBuffer = "reload"
+	updated, cmd = m.runCommand()
+	m = updated.(model)
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	assert.Contains(t, m.statusBar, "Reload failed")
+	assert.NotNil(t, m.tree.Node("count"))
+}
+
+func TestReload_Stdin(t *testing.T) {
+	m := newReloadModel(t, `{"a": 1}`)
+	m.commandBuffer = "reload"
+	updated, cmd := m.runCommand()
+	m = updated.(model)
+	assert.Nil(t, cmd)
+	assert.Equal(t, Error, m.mode)
+}
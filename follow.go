@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How often a followed file is checked for new data
+const followPollInterval = 250 * time.Millisecond
+
+// Maximum number of records added to the tree in one update
+const followBatchSize = 1000
+
+// Status bar message until the first record arrives
+const waitingForData = "Waiting for data..."
+
+// recordsMsg carries the records read since the last update. done is
+// set when the input ended, err when it is not valid JSON.
+type recordsMsg struct {
+	values []interface{}
+	done   bool
+	err    error
+}
+
+// followReader reads a file that keeps growing, like tail -f. At the end
+// of the file it waits for more data instead of returning io.EOF.
+type followReader struct {
+	file *os.File
+	poll time.Duration
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != io.EOF {
+			return n, err
+		}
+		time.Sleep(r.poll)
+	}
+}
+
+// readRecords decodes the JSON values of r as they arrive and sends them
+// to out. It closes out when the input ends or is invalid. Compressed
+// input is decompressed as it arrives.
+func readRecords(r io.Reader, out chan<- recordsMsg) {
+	defer close(out)
+
+	r, _, err := decompress(r)
+	if err != nil {
+		out <- recordsMsg{done: true, err: err}
+		return
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	for {
+		value, err := parseValue(dec)
+		if err == io.EOF {
+			out <- recordsMsg{done: true}
+			return
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			out <- recordsMsg{done: true, err: newParseError(dec, err)}
+			return
+		}
+		out <- recordsMsg{values: []interface{}{value}}
+	}
+}
+
+// waitForRecords returns a command that waits for the next records. The
+// records that are already waiting are sent together, so a fast producer
+// doesn't rebuild the lines once per record.
+func waitForRecords(in <-chan recordsMsg) tea.Cmd {
+	return func() tea.Msg {
+		batch, ok := <-in
+		if !ok {
+			return nil
+		}
+
+		for !batch.done && len(batch.values) < followBatchSize {
+			select {
+			case next, ok := <-in:
+				if !ok {
+					return batch
+				}
+				batch.values = append(batch.values, next.values...)
+				batch.done = next.done
+				batch.err = next.err
+			default:
+				return batch
+			}
+		}
+
+		return batch
+	}
+}
+
+// appendRecords adds the new records to the tree. When the cursor is on
+// the last line it sticks to the bottom and follows the new records, when
+// the user moved it up it stays where it is.
+func (m model) appendRecords(msg recordsMsg) (tea.Model, tea.Cmd) {
+	atBottom := m.visibleLines2.Len() == 0 ||
+		m.cursorY == m.visibleLines2.Len()-1
+
+	for _, value := range msg.values {
+		m.tree.AppendRecord(value)
+	}
+
+	if m.ready && len(msg.values) > 0 {
+		m.visibleLines2.UpdateContent2(m.tree)
+
+		if atBottom {
+			m.cursorY = m.visibleLines2.Len() - 1
+			m.updateCurrentPath()
+			m.ScrollDown()
+		}
+
+		m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
+			m.visibleLines2.total)
+	}
+
+	if msg.err != nil {
+		m.statusBar = errorStyle.Render("Error: " + msg.err.Error())
+	} else if msg.done {
+		m.statusBar = "End of input"
+	} else if m.statusBar == waitingForData {
+		m.statusBar = ""
+	}
+
+	if msg.done {
+		return m, nil
+	}
+	return m, waitForRecords(m.records)
+}
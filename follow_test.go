@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.ndjson")
+	assert.NoError(t, os.WriteFile(path, []byte("{\"n\": 1}\n"), 0o644))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	records := make(chan recordsMsg, 10)
+	go readRecords(&followReader{file: file, poll: time.Millisecond}, records)
+
+	msg := waitForRecords(records)().(recordsMsg)
+	assert.Equal(t, 1, len(msg.values))
+
+	// Data written later is picked up instead of ending at EOF
+	writer, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	defer writer.Close()
+	_, err = writer.WriteString("{\"n\": 2}\n")
+	assert.NoError(t, err)
+
+	msg = waitForRecords(records)().(recordsMsg)
+	assert.Equal(t, 1, len(msg.values))
+	assert.False(t, msg.done)
+}
+
+func TestWaitForRecords_Batch(t *testing.T) {
+	records := make(chan recordsMsg, 10)
+	readRecords(strings.NewReader(`{"n": 1} {"n": 2} {"n": 3}`), records)
+
+	// All the waiting records arrive in one message
+	msg := waitForRecords(records)().(recordsMsg)
+	assert.Equal(t, 3, len(msg.values))
+	assert.True(t, msg.done)
+	assert.NoError(t, msg.err)
+}
+
+func TestReadRecords_Error(t *testing.T) {
+	records := make(chan recordsMsg, 10)
+	readRecords(strings.NewReader(`{"n": 1} {"n": }`), records)
+
+	msg := waitForRecords(records)().(recordsMsg)
+	assert.Equal(t, 1, len(msg.values))
+	assert.True(t, msg.done)
+	assert.Error(t, msg.err)
+}
+
+func newFollowModel(height int) model {
+	tree := NewJSONTree()
+	tree.Stream = true
+
+	m := model{tree: tree, records: make(chan recordsMsg)}
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: height})
+	return updated.(model)
+}
+
+func TestAppendRecords_StickyBottom(t *testing.T) {
+	m := newFollowModel(10)
+
+	updated, _ := m.appendRecords(recordsMsg{values: []interface{}{
+		Object{{Key: "n", Value: 1}},
+	}})
+	m = updated.(model)
+
+	// The cursor follows the new records from the last line
+	assert.Equal(t, 2, m.cursorY)
+	updated, _ = m.appendRecords(recordsMsg{values: []interface{}{
+		Object{{Key: "n", Value: 2}},
+	}})
+	m = updated.(model)
+	assert.Equal(t, 5, m.cursorY)
+
+	// After moving up, the cursor stays where it is
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")})
+	m = updated.(model)
+	updated, _ = m.appendRecords(recordsMsg{values: []interface{}{
+		Object{{Key: "n", Value: 3}},
+	}})
+	m = updated.(model)
+	assert.Equal(t, 4, m.cursorY)
+	assert.Equal(t, 9, m.visibleLines2.Len())
+}
+
+func TestAppendRecords_Done(t *testing.T) {
+	m := newFollowModel(10)
+
+	updated, cmd := m.appendRecords(recordsMsg{done: true, err: io.ErrUnexpectedEOF})
+	m = updated.(model)
+
+	assert.Nil(t, cmd, "Stop waiting for records")
+	assert.Contains(t, m.statusBar, io.ErrUnexpectedEOF.Error())
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// match tells how sure a detector is that the input has its format
+type match int
+
+const (
+	noMatch      match = iota
+	contentMatch       // the input starts like the format
+	nameMatch          // the file name or its extension belongs to the format
+)
+
+// inputFormat is a format vj can read. detect recognizes it from the file
+// name, which is empty for stdin, and from the input; decode builds the
+// tree, or on a parse error the part read before the error.
+type inputFormat struct {
+	name   string
+	detect func(path string, input []byte) match
+	decode func(input []byte, opts options) (*JSONTree, error)
+}
+
+// The name of the format used when no other format is detected
+const defaultFormat = "json"
+
+// Formats vj can read. To add a format, add it here with a detector and a
+// decoder.
+var inputFormats = []inputFormat{
+	{"json", detectNothing, decodeJSON},
+	{"jsonc", detectJSONC, decodeLenient},
+	{"json5", detectExt(".json5"), decodeLenient},
+	{"yaml", detectYAML, decodeYAML},
+	{"toml", detectExt(".toml"), decodeTOML},
+	{"csv", detectExt(".csv"), decodeCSV},
+	{"tsv", detectExt(".tsv", ".tab"), decodeCSV},
+	{"cbor", detectBinary("cbor", ".cbor"), decodeBinaryFormat(ParseCBOR)},
+	{"msgpack", detectBinary("msgpack", ".msgpack", ".mpk"), decodeBinaryFormat(ParseMsgpack)},
+	{"text", detectExt(".log"), decodeText},
+}
+
+// findFormat returns the format with the given name
+func findFormat(name string) (inputFormat, bool) {
+	i := slices.IndexFunc(inputFormats, func(f inputFormat) bool {
+		return f.name == name
+	})
+	if i < 0 {
+		return inputFormat{}, false
+	}
+	return inputFormats[i], true
+}
+
+// formatNames returns the names of the formats, for --format
+func formatNames() []string {
+	names := make([]string, len(inputFormats))
+	for i, format := range inputFormats {
+		names[i] = format.name
+	}
+	return names
+}
+
+// detectFormat returns the name of the format of the input. A match on
+// the file name is preferred to a match on the content, and on a tie the
+// first format of the list wins.
+func detectFormat(path string, input []byte) string {
+	name, best := defaultFormat, noMatch
+	for _, format := range inputFormats {
+		if m := format.detect(path, input); m > best {
+			name, best = format.name, m
+		}
+	}
+	return name
+}
+
+// hasExt reports whether the file has one of the extensions. The
+// extension of a compressed file is ignored, data.csv.gz is a CSV file.
+func hasExt(path string, exts ...string) bool {
+	ext := strings.ToLower(filepath.Ext(stripCompressionExt(path)))
+	return ext != "" && slices.Contains(exts, ext)
+}
+
+func detectNothing(string, []byte) match {
+	return noMatch
+}
+
+// detectExt returns a detector that recognizes files by their extension
+func detectExt(exts ...string) func(string, []byte) match {
+	return func(path string, _ []byte) match {
+		if hasExt(path, exts...) {
+			return nameMatch
+		}
+		return noMatch
+	}
+}
+
+func detectJSONC(path string, _ []byte) match {
+	if !hasExt(path, ".json5") && isLenientFile(path) {
+		return nameMatch
+	}
+	return noMatch
+}
+
+// detectYAML recognizes YAML files, and YAML input that starts with a
+// directive or a document marker, which JSON never does
+func detectYAML(path string, input []byte) match {
+	if hasExt(path, ".yaml", ".yml") {
+		return nameMatch
+	}
+	if bytes.HasPrefix(input, []byte("%YAML")) || bytes.HasPrefix(input, []byte("---\n")) ||
+		bytes.HasPrefix(input, []byte("---\r\n")) {
+		return contentMatch
+	}
+	return noMatch
+}
+
+// detectBinary returns a detector for CBOR or MessagePack, which are
+// recognized by their extensions or by their first bytes
+func detectBinary(format string, exts ...string) func(string, []byte) match {
+	return func(path string, input []byte) match {
+		if hasExt(path, exts...) {
+			return nameMatch
+		}
+		if sniffBinaryFormat(input) == format {
+			return contentMatch
+		}
+		return noMatch
+	}
+}
+
+// decodeJSON reads JSON. Concatenated top-level values, as in NDJSON,
+// become a stream of records; a single value is a regular document
+// unless the NDJSON mode was requested.
+func decodeJSON(input []byte, opts options) (*JSONTree, error) {
+	records, err := ParseJSONStream(bytes.NewReader(input))
+	if len(records) == 0 && err == nil {
+		err = &ParseError{Err: errors.New("no JSON value in input")}
+	}
+	return buildRecords(records, opts, err)
+}
+
+// decodeLenient reads JSONC and JSON5
+func decodeLenient(input []byte, _ options) (*JSONTree, error) {
+	data, err := ParseLenient(input)
+	if data == nil {
+		return nil, err
+	}
+	return BuildTree(data, "", nil), err
+}
+
+// decodeYAML reads a YAML stream, its documents are records like NDJSON
+// values
+func decodeYAML(input []byte, opts options) (*JSONTree, error) {
+	docs, err := ParseYAML(input)
+	if len(docs) == 0 && err == nil {
+		err = &ParseError{Err: errors.New("no YAML document in input")}
+	}
+	return buildRecords(docs, opts, err)
+}
+
+// decodeTOML reads a TOML document as a single object
+func decodeTOML(input []byte, _ options) (*JSONTree, error) {
+	data, err := ParseTOML(input)
+	return BuildTree(data, "", nil), err
+}
+
+// decodeCSV reads the rows of a CSV or TSV file as an array of objects
+func decodeCSV(input []byte, opts options) (*JSONTree, error) {
+	rows, err := ParseCSV(input, opts)
+	return BuildTree(rows, "", nil), err
+}
+
+// decodeBinaryFormat returns the decoder of a binary format, a sequence of
+// its items is a stream like NDJSON
+func decodeBinaryFormat(parse func([]byte) ([]interface{}, error)) func([]byte, options) (*JSONTree, error) {
+	return func(input []byte, opts options) (*JSONTree, error) {
+		items, err := parse(input)
+		if len(items) == 0 && err == nil {
+			err = &ParseError{Err: errors.New("empty input")}
+		}
+		return buildRecords(items, opts, err)
+	}
+}
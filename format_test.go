@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path     string
+		input    string
+		expected string
+	}{
+		{"data.json", `{"a": 1}`, "json"},
+		{"", `{"a": 1}`, "json"},
+		{"deploy.yaml", "a: 1", "yaml"},
+		{"ci/config.YML", "a: 1", "yaml"},
+		{"", "---\na: 1\n", "yaml"},
+		{"Cargo.toml", "[package]", "toml"},
+		{"export.csv.gz", "a,b", "csv"},
+		{"export.tsv", "a\tb", "tsv"},
+		{"tsconfig.json", "{}", "jsonc"},
+		{"config.json5", "{}", "json5"},
+		{"", "\xd9\xd9\xf7\x01", "cbor"},
+		{"", "\x81\xa1a\x01", "msgpack"},
+		{"logs/app.log.gz", "INFO body={}", "text"},
+		// The file name wins over the content
+		{"data.cbor", "\x81\xa1a\x01", "cbor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectFormat(tt.path, []byte(tt.input)))
+		})
+	}
+}
+
+func TestFormatRegistry(t *testing.T) {
+	// Every format can be found by name
+	for _, name := range formatNames() {
+		format, found := findFormat(name)
+		assert.True(t, found)
+		assert.Equal(t, name, format.name)
+	}
+
+	_, found := findFormat("xml")
+	assert.False(t, found)
+
+	_, err := loadTree([]byte("<a/>"), options{format: "xml"})
+	assert.EqualError(t, err, `unknown format "xml"`)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// options are the command line settings that control how the input
+// is read and parsed
+type options struct {
+	ndjson bool
+	follow bool
+	format string // name of the input format, detected when empty
+
+	// CSV and TSV settings
+	delimiter  rune // cell separator, the default of the format when 0
+	noHeader   bool // the first row is data, not the keys
+	inferTypes bool // read numbers and booleans instead of strings
+}
+
+// loadTree parses the input with the decoder of opts.format and builds
+// the tree. Without a format, it is detected from the content of the
+// input. On a parse error the tree holds what was read before the error,
+// or is nil.
+func loadTree(input []byte, opts options) (*JSONTree, error) {
+	if opts.format == "" {
+		opts.format = detectFormat("", input)
+	}
+
+	format, found := findFormat(opts.format)
+	if !found {
+		return nil, fmt.Errorf("unknown format %q", opts.format)
+	}
+	return format.decode(input, opts)
+}
+
+// buildRecords builds the tree of the top-level values of the input
+func buildRecords(records []interface{}, opts options, err error) (*JSONTree, error) {
+	if len(records) == 0 {
+		return nil, err
+	}
+
+	if len(records) == 1 && !opts.ndjson {
+		return BuildTree(records[0], "", nil), err
+	}
+
+	return BuildTree(Stream(records), "", nil), err
+}
+
+// Config files that are JSONC even though their extension is .json
+var lenientFileNames = []string{
+	"tsconfig.json", "jsconfig.json", "devcontainer.json",
+	".devcontainer.json", ".eslintrc.json", ".babelrc", ".babelrc.json",
+}
+
+// VS Code config files, which are JSONC only in a .vscode directory: a
+// settings.json elsewhere is as likely to be plain JSON
+var vscodeFileNames = []string{
+	"settings.json", "keybindings.json", "launch.json", "tasks.json",
+	"extensions.json",
+}
+
+// isLenientFile reports whether the file is likely JSONC or JSON5, from
+// its extension or its well-known name
+func isLenientFile(path string) bool {
+	path = stripCompressionExt(path)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonc", ".json5":
+		return true
+	}
+
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, "tsconfig.") && strings.HasSuffix(name, ".json") {
+		// tsconfig.base.json, tsconfig.app.json...
+		return true
+	}
+	if filepath.Base(filepath.Dir(path)) == ".vscode" && slices.Contains(vscodeFileNames, name) {
+		return true
+	}
+	return slices.Contains(lenientFileNames, name)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTree_Stream(t *testing.T) {
+	input := "{\"id\": 1, \"tags\": [\"a\"]}\n{\"id\": 2}\n[true]\n"
+
+	tree, err := loadTree([]byte(input), options{})
+	assert.NoError(t, err)
+	assert.True(t, tree.Stream)
+
+	// Records are top-level nodes without a root
+	_, hasRoot := tree.GetNode("")
+	assert.False(t, hasRoot)
+	assert.Equal(t, []string{"0", "1", "2"}, tree.GetChildren(""))
+	assert.Equal(t, "a", mustGetValue(t, tree, "0.tags[0]"))
+	assert.Equal(t, true, mustGetValue(t, tree, "2[0]"))
+
+	// Each record starts on its own line
+	assert.Equal(t, 0, tree.LineNumber(tree.Node("0").ID))
+	assert.Equal(t, 6, tree.LineNumber(tree.Node("1").ID))
+	assert.Equal(t, 9, tree.LineNumber(tree.Node("2").ID))
+}
+
+func TestLoadTree_SingleDocument(t *testing.T) {
+	tree, err := loadTree([]byte(`{"id": 1}`), options{})
+	assert.NoError(t, err)
+	assert.False(t, tree.Stream)
+
+	// With the NDJSON mode a single value is a stream of one record
+	tree, err = loadTree([]byte(`{"id": 1}`), options{ndjson: true})
+	assert.NoError(t, err)
+	assert.True(t, tree.Stream)
+	assert.Equal(t, []string{"0"}, tree.GetChildren(""))
+}
+
+func TestLoadTree_StreamRendering(t *testing.T) {
+	currentTheme = themes["nocolor"]
+	tree, err := loadTree([]byte("{\"id\": 1}\n{\"id\": 2}\n[3]"), options{})
+	assert.NoError(t, err)
+
+	var lines []string
+	for _, line := range tree.PrintAsJSON2() {
+		lines = append(lines, RenderLine(line, false))
+	}
+
+	// Records are not separated by commas
+	expected := "{\n  \"id\": 1\n}\n{\n  \"id\": 2\n}\n[\n  3\n]"
+	assert.Equal(t, expected, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Size from which a JSON file is indexed instead of being read in memory
+const lazyThreshold = 64 << 20
+
+// Size of the chunks the scanner reads
+const scanBufferSize = 1 << 20
+
+// Bytes that skipContainer stops at, a table is faster than
+// bytes.IndexAny
+var containerStops = func() (stops [256]bool) {
+	for _, c := range []byte(`"{}[]`) {
+		stops[c] = true
+	}
+	return stops
+}()
+
+// pending is an object or an array that was found in the input but not
+// built yet: the offsets of its opening and its closing bracket
+type pending struct {
+	start int64
+	end   int64 // after the closing bracket
+	array bool
+}
+
+// isLazyFile reports whether the file is big enough to be indexed, and
+// holds JSON that is not compressed
+func isLazyFile(path string, opts options) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() < lazyThreshold {
+		return false
+	}
+	if opts.follow || (opts.format != "" && opts.format != defaultFormat) {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	if bytes.HasPrefix(head, gzipMagic) || bytes.HasPrefix(head, bzip2Magic) {
+		return false
+	}
+	return opts.format == defaultFormat || detectFormat(path, head) == defaultFormat
+}
+
+// openLazy indexes a JSON file. The file is read when the nodes are
+// unfolded, so it must stay open while the tree is used.
+func openLazy(file *os.File, opts options) (*JSONTree, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return loadLazy(file, info.Size(), opts)
+}
+
+// loadLazy builds the top-level nodes of a JSON document or of a stream of
+// records in a single pass over the input. Nested objects and arrays are
+// only skipped, they are built from their bytes when they are unfolded or
+// a path goes through them. On a parse error the tree holds the nodes
+// read before the error.
+func loadLazy(r io.ReaderAt, size int64, opts options) (*JSONTree, error) {
+	tree := NewJSONTree()
+	tree.source = r
+
+	var err error
+	readTopLevel(newScanner(io.NewSectionReader(r, 0, size), 0), opts,
+		func(batch loadBatch) bool {
+			tree.applyBatch(batch)
+			err = batch.err
+			return true
+		})
+	if tree.Len() == 0 {
+		return nil, err
+	}
+	return tree, err
+}
+
+// pend keeps a nested value for later: the node is folded and gets the
+// line of its closing bracket, its children are built by load
+func (jt *JSONTree) pend(id NodeID, value pending) {
+	for len(jt.spans) <= int(id) {
+		jt.spans = append(jt.spans, pending{})
+	}
+	jt.spans[id] = value
+	jt.collapsed[id] = true
+
+	node := &jt.nodes[id]
+	node.closingLine = jt.addLine(id, true)
+	jt.lines.set(node.closingLine, false)
+}
+
+// isPending reports whether the children of the node were not built yet
+func (jt *JSONTree) isPending(id NodeID) bool {
+	return int(id) < len(jt.spans) && jt.spans[id].end > 0
+}
+
+// hasPending reports whether some children are not read from the input yet
+func (jt *JSONTree) hasPending() bool {
+	for _, span := range jt.spans {
+		if span.end > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// load builds the children of a pending node from the input, their own
+// children are pending. Their lines are spliced in the place of the
+// closing line of the node, the lines after them are not numbered again.
+func (jt *JSONTree) load(id NodeID) error {
+	if !jt.isPending(id) {
+		return nil
+	}
+	span := jt.spans[id]
+	s := newScanner(io.NewSectionReader(jt.source, span.start, span.end-span.start), span.start)
+	value, err := s.scanContainer()
+	if err != nil {
+		return fmt.Errorf("cannot read .%s: %w", jt.Path(id), err)
+	}
+
+	jt.spans[id] = pending{}
+	jt.removeLinesInside(id)
+	jt.spliceLines(jt.LineNumber(id)+1, func() { jt.buildChildren(id, value) })
+	jt.showSpliced(id)
+	return nil
+}
+
+// Close closes the input of a tree loaded with openLazy
+func (jt *JSONTree) Close() error {
+	if closer, ok := jt.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// scanner reads JSON values without building the nested ones: it only
+// finds where they start and end. Syntax errors inside the skipped
+// values are found when they are built.
+type scanner struct {
+	r      io.Reader
+	buf    []byte
+	pos    int   // next byte of buf
+	offset int64 // offset of buf[0] in the input
+	err    error // of the last read
+
+	// onRead is called after each chunk with the offset reached, an
+	// error stops the scanner
+	onRead func(offset int64) error
+}
+
+// newScanner reads a section of the input at offset. A small section,
+// like the span of a nested value, gets a buffer of its size: one more
+// byte, so the end is found by the first read.
+func newScanner(r *io.SectionReader, offset int64) *scanner {
+	size := min(scanBufferSize, r.Size()+1)
+	return &scanner{r: r, buf: make([]byte, 0, size), offset: offset}
+}
+
+// fill reads the next chunk when buf was read to the end, it returns
+// false at the end of the input
+func (s *scanner) fill() bool {
+	if s.pos < len(s.buf) {
+		return true
+	}
+	if s.err != nil {
+		return false
+	}
+
+	s.offset += int64(len(s.buf))
+	s.pos = 0
+	n, err := io.ReadFull(s.r, s.buf[:cap(s.buf)])
+	s.buf = s.buf[:n]
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if s.onRead != nil && err == nil {
+		err = s.onRead(s.offset + int64(n))
+	}
+	s.err = err
+	return n > 0
+}
+
+// at returns the offset of the next byte
+func (s *scanner) at() int64 {
+	return s.offset + int64(s.pos)
+}
+
+// errorf returns a parse error at the next byte
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return &ParseError{Err: fmt.Errorf(format, args...), Offset: s.at()}
+}
+
+// eof returns the error for input that ended in the middle of a value
+func (s *scanner) eof() error {
+	if s.err != nil && s.err != io.EOF {
+		return s.err
+	}
+	return &ParseError{Err: io.ErrUnexpectedEOF, Offset: s.at()}
+}
+
+// skipSpace returns the next byte that is not whitespace, without
+// consuming it, or io.EOF
+func (s *scanner) skipSpace() (byte, error) {
+	for s.fill() {
+		for ; s.pos < len(s.buf); s.pos++ {
+			switch c := s.buf[s.pos]; c {
+			case ' ', '\t', '\n', '\r':
+			default:
+				return c, nil
+			}
+		}
+	}
+	if s.err != io.EOF {
+		return 0, s.err
+	}
+	return 0, io.EOF
+}
+
+// expect consumes the next byte that is not whitespace, which must be c
+func (s *scanner) expect(c byte) error {
+	next, err := s.skipSpace()
+	if err == io.EOF {
+		return s.eof()
+	} else if err != nil {
+		return err
+	}
+	if next != c {
+		return s.errorf("invalid character %q, expected %q", next, c)
+	}
+	s.pos++
+	return nil
+}
+
+// scanValue reads the next value. Objects and arrays are skipped and
+// returned as pending.
+func (s *scanner) scanValue() (interface{}, error) {
+	c, err := s.skipSpace()
+	if err == io.EOF {
+		return nil, s.eof()
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case '{', '[':
+		start := s.at()
+		if err := s.skipContainer(); err != nil {
+			return nil, err
+		}
+		return pending{start: start, end: s.at(), array: c == '['}, nil
+	case '"':
+		raw, err := s.readString()
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, &ParseError{Err: err, Offset: s.at() - int64(len(raw))}
+		}
+		return value, nil
+	}
+	return s.readLiteral()
+}
+
+// scanContainer reads the object or the array at the next byte, its
+// nested values are pending
+func (s *scanner) scanContainer() (interface{}, error) {
+	c, err := s.skipSpace()
+	if err != nil {
+		return nil, s.eof()
+	}
+
+	var members []Member
+	err = s.members(func(member Member) error {
+		members = append(members, member)
+		return nil
+	})
+	switch c {
+	case '{':
+		return append(Object{}, members...), err
+	case '[':
+		arr := make([]interface{}, len(members))
+		for i, member := range members {
+			arr[i] = member.Value
+		}
+		return arr, err
+	}
+	return nil, err
+}
+
+// members reads the object or the array at the next byte and calls add
+// with each of its members, or each of its elements without a key
+func (s *scanner) members(add func(Member) error) error {
+	c, err := s.skipSpace()
+	if err != nil {
+		return s.eof()
+	}
+	if c != '{' && c != '[' {
+		return s.errorf("invalid character %q, expected an object or an array", c)
+	}
+	s.pos++
+
+	closing := byte(']')
+	if c == '{' {
+		closing = '}'
+	}
+	if next, err := s.skipSpace(); err == nil && next == closing {
+		s.pos++
+		return nil
+	}
+
+	for {
+		var key string
+		if c == '{' {
+			if next, err := s.skipSpace(); err != nil {
+				return s.eof()
+			} else if next != '"' {
+				return s.errorf("invalid character %q, expected an object key", next)
+			}
+			raw, err := s.readString()
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(raw, &key); err != nil {
+				return &ParseError{Err: err, Offset: s.at() - int64(len(raw))}
+			}
+			if err := s.expect(':'); err != nil {
+				return err
+			}
+		}
+
+		value, err := s.scanValue()
+		if err != nil {
+			return err
+		}
+		if err := add(Member{Key: key, Value: value}); err != nil {
+			return err
+		}
+		if done, err := s.endOfMember(closing); done || err != nil {
+			return err
+		}
+	}
+}
+
+// next returns the first byte of the next top-level value and its
+// offset, or io.EOF
+func (s *scanner) next() (byte, int64, error) {
+	c, err := s.skipSpace()
+	return c, s.at(), err
+}
+
+// value reads the next top-level value, it is pending when it is an
+// object or an array
+func (s *scanner) value() (interface{}, error) {
+	return s.scanValue()
+}
+
+// record returns the first value of the input, that was read member by
+// member, as the first record of a stream: it is read again when it is
+// unfolded
+func (s *scanner) record(start int64, end int64, first byte) (interface{}, error) {
+	return pending{start: start, end: end, array: first == '['}, nil
+}
+
+// endOfMember consumes the comma after a member or an element, or the
+// closing bracket, then it reports true
+func (s *scanner) endOfMember(closing byte) (bool, error) {
+	c, err := s.skipSpace()
+	if err != nil {
+		return false, s.eof()
+	}
+	switch c {
+	case ',':
+		s.pos++
+		return false, nil
+	case closing:
+		s.pos++
+		return true, nil
+	}
+	return false, s.errorf("invalid character %q after a value, expected ',' or %q", c, closing)
+}
+
+// skipContainer skips the object or the array at the next byte, up to
+// its closing bracket. It is the loop that reads most of a big file, so
+// the strings are skipped here too.
+func (s *scanner) skipContainer() error {
+	var stack []byte // closing brackets of the open containers
+	inString, escaped := false, false
+	for s.fill() {
+		buf := s.buf
+		for i := s.pos; i < len(buf); i++ {
+			if inString {
+				// Jump to the end of the string, or to the next escape
+				if escaped {
+					escaped = false
+					continue
+				}
+				rest := buf[i:]
+				end := bytes.IndexByte(rest, '"')
+				if end < 0 {
+					end = len(rest)
+				}
+				if j := bytes.IndexByte(rest[:end], '\\'); j >= 0 {
+					i += j
+					escaped = true
+					continue
+				}
+				i += end
+				inString = i == len(buf)
+				continue
+			}
+
+			c := buf[i]
+			if !containerStops[c] {
+				continue
+			}
+
+			switch c {
+			case '"':
+				inString = true
+			case '{':
+				stack = append(stack, '}')
+			case '[':
+				stack = append(stack, ']')
+			default:
+				s.pos = i
+				if len(stack) == 0 || stack[len(stack)-1] != c {
+					return s.errorf("invalid character %q, brackets do not match", c)
+				}
+				stack = stack[:len(stack)-1]
+				if len(stack) == 0 {
+					s.pos = i + 1
+					return nil
+				}
+			}
+		}
+		s.pos = len(buf)
+	}
+	return s.eof()
+}
+
+// readString returns the string at the next byte with its quotes and
+// escapes, as it is in the input
+func (s *scanner) readString() ([]byte, error) {
+	var raw []byte
+	start := s.pos
+	s.pos++ // the opening quote
+	escaped := false
+	for {
+		// The escaped byte may be at the start of the next chunk
+		if escaped && s.pos < len(s.buf) {
+			s.pos++
+			escaped = false
+		}
+
+		rest := s.buf[s.pos:]
+		end := bytes.IndexByte(rest, '"')
+		if end < 0 {
+			end = len(rest)
+		}
+		if i := bytes.IndexByte(rest[:end], '\\'); i >= 0 {
+			s.pos += i + 1
+			escaped = true
+			continue
+		}
+		if end < len(rest) {
+			s.pos += end + 1
+			return append(raw, s.buf[start:s.pos]...), nil
+		}
+
+		raw = append(raw, s.buf[start:]...)
+		s.pos = len(s.buf)
+		if !s.fill() {
+			return nil, s.eof()
+		}
+		start = s.pos
+	}
+}
+
+// readLiteral reads a number, true, false or null
+func (s *scanner) readLiteral() (interface{}, error) {
+	offset := s.at()
+	var raw []byte
+	for done := false; !done && s.fill(); {
+		start := s.pos
+		for ; s.pos < len(s.buf); s.pos++ {
+			if c := s.buf[s.pos]; c == ',' || c == ']' || c == '}' ||
+				c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				done = true
+				break
+			}
+		}
+		raw = append(raw, s.buf[start:s.pos]...)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil || dec.InputOffset() != int64(len(raw)) {
+		if len(raw) > 20 {
+			raw = append(raw[:20], "…"...)
+		}
+		return nil, &ParseError{Err: fmt.Errorf("invalid value %q", raw), Offset: offset}
+	}
+	return value, nil
+}
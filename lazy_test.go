@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mustLoadLazy indexes the input like a big file
+func mustLoadLazy(t *testing.T, input string, opts options) *JSONTree {
+	t.Helper()
+	tree, err := loadLazy(strings.NewReader(input), int64(len(input)), opts)
+	assert.NoError(t, err)
+	return tree
+}
+
+func TestLoadLazy(t *testing.T) {
+	input := `{"a": {"b": [1, {"c": "}"}]}, "q\"": "x\"]", "d": [], "e": 1.5, "n": null}`
+	tree := mustLoadLazy(t, input, options{})
+
+	// The top-level nodes are built, the nested values are folded and
+	// their children are not built
+	assert.Equal(t, 6, tree.Len())
+	assert.True(t, tree.IsCollapsed("a"))
+	assert.True(t, tree.isPending(tree.lookup("a")))
+	assert.Equal(t, `x"]`, tree.Node(`["q\""]`).Value)
+	assert.Equal(t, 7, tree.VisibleLen())
+	assert.True(t, tree.Line(1).HasChildren)
+
+	// Unfolding reads the children from the input
+	tree.Expand("a")
+	assert.False(t, tree.isPending(tree.lookup("a")))
+	assert.True(t, tree.IsCollapsed("a.b"))
+	assert.Equal(t, 9, tree.VisibleLen())
+	assertLines(t, tree)
+
+	// A path reads the nodes it goes through
+	assert.Equal(t, "}", tree.Node("a.b[1].c").Value)
+	assertLines(t, tree)
+
+	// The values are the same as when the whole input is parsed
+	assert.Equal(t, mustGetValue(t, mustLoadTree(t, input), ""), mustGetValue(t, tree, ""))
+	assert.Equal(t, tree.Len(), mustLoadTree(t, input).Len())
+}
+
+func TestLoadLazy_Stream(t *testing.T) {
+	tree := mustLoadLazy(t, "{\"n\": 1}\n{\"n\": [2]}\n3\nnull\n", options{})
+
+	// The first record was read to tell a document from a stream, then
+	// folded like the others
+	assert.True(t, tree.Stream)
+	assert.Equal(t, 4, tree.Len())
+	assert.True(t, tree.IsCollapsed("0"))
+	assert.True(t, tree.IsCollapsed("1"))
+	assert.Equal(t, 4, tree.VisibleLen())
+	assert.Nil(t, tree.Node("3").Value)
+	assert.Equal(t, NullType, tree.Node("3").Type)
+
+	assert.Equal(t, ArrayType, tree.Node("1.n").Type)
+	assert.True(t, tree.IsCollapsed("1.n"))
+	assertLines(t, tree)
+}
+
+func TestLoadLazy_Errors(t *testing.T) {
+	// A nested value is checked when it is read
+	tree := mustLoadLazy(t, `{"a": {"b": }, "c": 1}`, options{})
+	tree.Expand("a")
+	assert.True(t, tree.IsCollapsed("a"))
+
+	_, err := tree.Resolve(".a.b")
+	var parseErr *ParseError
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, int64(12), parseErr.Offset)
+
+	// The value of the document is not built without the invalid part
+	value, err := tree.GetValue("")
+	assert.Nil(t, value)
+	assert.True(t, errors.As(err, &parseErr))
+
+	// The top-level nodes read before an error are kept
+	input := `{"a": 1, "b": [2], "c": tru}`
+	tree, err = loadLazy(strings.NewReader(input), int64(len(input)), options{})
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, int64(len(`{"a": 1, "b": [2], "c": `)), parseErr.Offset)
+	assert.Equal(t, []string{"", "a", "b"}, tree.GetAllPaths())
+
+	_, err = loadLazy(strings.NewReader(` `), 1, options{})
+	assert.ErrorContains(t, err, "no JSON value in input")
+}
+
+func TestScanner_Chunks(t *testing.T) {
+	input := `{"k\\\"": "v\\", "a": [1, "]\"["], "o": {"x": {}}, "t": true, "s": "é"}`
+	expected, err := ParseJSON(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	// Strings and escapes are cut at every place between two chunks
+	for size := 1; size <= 8; size++ {
+		s := &scanner{r: strings.NewReader(input), buf: make([]byte, 0, size)}
+		value, err := s.scanContainer()
+		assert.NoError(t, err, "chunks of %d bytes", size)
+
+		obj := value.(Object)
+		assert.Equal(t, len(expected.(Object)), len(obj))
+		for i, member := range obj {
+			want := expected.(Object)[i]
+			assert.Equal(t, want.Key, member.Key)
+			if span, ok := member.Value.(pending); ok {
+				nested, err := ParseJSON(bytes.NewReader([]byte(input[span.start:span.end])))
+				assert.NoError(t, err)
+				member.Value = nested
+			}
+			assert.Equal(t, want.Value, member.Value, "chunks of %d bytes", size)
+		}
+	}
+}
+
+func TestMatchNodes_LoadsLazyNodes(t *testing.T) {
+	old := mustLoadLazy(t, `{"a": {"b": {"c": 1}}, "d": [1]}`, options{})
+	old.Expand("a")
+	old.Expand("a.b")
+
+	tree := mustLoadLazy(t, `{"a": {"b": {"c": 2}}, "d": [1]}`, options{})
+	matches := matchNodes(old, tree)
+	assert.Equal(t, tree.lookup("a.b.c"), matches[old.lookup("a.b.c")])
+	assert.True(t, tree.isPending(tree.lookup("d")))
+	assertLines(t, tree)
+	assert.Equal(t, Modified, diffTrees(old, tree, matches)[tree.lookup("a.b.c")])
+}
+
+func TestSearch_LazyTree(t *testing.T) {
+	tree := mustLoadLazy(t, `{"a": {"b": "needle"}, "c": "needle"}`, options{})
+	m := model{tree: tree, searchBuffer: "needle"}
+
+	// The folded values are not read for a search, the status bar says so
+	m.performSearch()
+	assert.Len(t, m.searchResults, 1)
+	assert.Equal(t, "/needle [1/1] (values not read yet are not searched)", m.statusBar)
+
+	tree.Expand("a")
+	m.performSearch()
+	assert.Len(t, m.searchResults, 2)
+	assert.Equal(t, "/needle [1/2]", m.statusBar)
+}
+
+func BenchmarkLoadLazy(b *testing.B) {
+	input := benchmarkDocument(100000)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := loadLazy(bytes.NewReader(input), int64(len(input)), options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLoadLazy_LoadCostDoesNotGrow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("times loading every value of a big document")
+	}
+
+	// perSpan loads every pending value of a document of n records, and
+	// returns the time it took for each of them
+	perSpan := func(n int) time.Duration {
+		input := benchmarkDocument(n)
+		tree, err := loadLazy(bytes.NewReader(input), int64(len(input)), options{})
+		assert.NoError(t, err)
+
+		start := time.Now()
+		spans := 0
+		for id := rootID; int(id) < len(tree.nodes); id++ {
+			if tree.isPending(id) {
+				assert.NoError(t, tree.load(id))
+				spans++
+			}
+		}
+		elapsed := time.Since(start)
+		assertLines(t, tree)
+		return elapsed / time.Duration(spans)
+	}
+
+	// A document 16 times bigger takes about as long for each value, the
+	// lines after a loaded value are not numbered again
+	small := min(perSpan(1000), perSpan(1000))
+	big := perSpan(16000)
+	assert.Less(t, big, 4*small, "%v for each value of the big document, %v for the small one", big, small)
+}
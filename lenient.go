@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ParseLenient reads a JSONC or JSON5 document, as used by tsconfig.json,
+// VS Code settings and devcontainer.json. On top of JSON it accepts
+// comments, trailing commas, unquoted keys, single-quoted strings and the
+// JSON5 number forms (hex, leading "+" or ".", Infinity and NaN).
+//
+// Comments are kept as notes on the values they annotate: the comments
+// before a member or element, and a comment on the same line after it.
+// Like ParseJSON, it returns a *ParseError and the part of the document
+// read before the error on invalid input.
+func ParseLenient(src []byte) (interface{}, error) {
+	p := &lenientParser{src: src}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return value, p.error(err)
+	}
+
+	if comments := p.skipSpace(); len(comments) > 0 {
+		value = annotate(value, comments)
+	}
+	if p.pos < len(p.src) {
+		return value, p.error(errors.New("invalid data after top-level value"))
+	}
+
+	return value, nil
+}
+
+type lenientParser struct {
+	src   []byte
+	pos   int
+	depth int // objects and arrays around the value being read
+}
+
+func (p *lenientParser) error(err error) *ParseError {
+	return &ParseError{Err: err, Offset: int64(p.pos)}
+}
+
+// skipSpace skips whitespace and comments, and returns the comments
+func (p *lenientParser) skipSpace() []string {
+	var comments []string
+	for p.pos < len(p.src) {
+		if comment, ok := p.skipComment(); ok {
+			comments = append(comments, comment)
+			continue
+		}
+
+		r, size := utf8.DecodeRune(p.src[p.pos:])
+		if !unicode.IsSpace(r) && r != '\uFEFF' {
+			break
+		}
+		p.pos += size
+	}
+	return comments
+}
+
+// skipLineSpace skips whitespace and comments up to the end of the line,
+// and returns the comments. It is used to find a comment that follows a
+// value on the same line.
+func (p *lenientParser) skipLineSpace() []string {
+	var comments []string
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		if comment, ok := p.skipComment(); ok {
+			comments = append(comments, comment)
+			continue
+		}
+
+		if p.src[p.pos] != ' ' && p.src[p.pos] != '\t' && p.src[p.pos] != '\r' {
+			break
+		}
+		p.pos++
+	}
+	return comments
+}
+
+func (p *lenientParser) skipComment() (string, bool) {
+	rest := p.src[p.pos:]
+
+	switch {
+	case len(rest) >= 2 && rest[0] == '/' && rest[1] == '/':
+		end := strings.IndexByte(string(rest), '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		p.pos += end
+		return strings.TrimSpace(string(rest[:end])), true
+
+	case len(rest) >= 2 && rest[0] == '/' && rest[1] == '*':
+		end := strings.Index(string(rest[2:]), "*/")
+		if end < 0 {
+			// Unterminated comment, let the caller fail on it
+			return "", false
+		}
+		p.pos += end + 4
+
+		// Notes are shown on a single line
+		return strings.Join(strings.Fields(string(rest[:end+4])), " "), true
+	}
+
+	return "", false
+}
+
+func (p *lenientParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *lenientParser) parseValue() (interface{}, error) {
+	comments := p.skipSpace()
+
+	start := p.pos
+	value, err := p.parseBareValue()
+	if len(comments) > 0 && (err == nil || p.pos > start) {
+		// The comments go to the value read, null included, but not to
+		// the missing value of an error
+		value = annotate(value, comments)
+	}
+	return value, err
+}
+
+func (p *lenientParser) parseBareValue() (interface{}, error) {
+	if p.pos >= len(p.src) {
+		return nil, errors.New("unexpected end of input")
+	}
+
+	switch c := p.peek(); {
+	case c == '{' || c == '[':
+		if p.depth == maxNestingDepth {
+			return nil, fmt.Errorf("values nested more than %d levels deep", maxNestingDepth)
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+
+		if c == '{' {
+			return p.parseObject()
+		}
+		return p.parseArray()
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	}
+
+	word := p.parseIdentifier()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "Infinity", "NaN":
+		return json.Number(word), nil
+	case "":
+		r, _ := utf8.DecodeRune(p.src[p.pos:])
+		return nil, fmt.Errorf("invalid character %q looking for beginning of value", r)
+	}
+
+	p.pos -= len(word)
+	return nil, fmt.Errorf("invalid literal %q", word)
+}
+
+func (p *lenientParser) parseObject() (interface{}, error) {
+	obj := Object{}
+	p.pos++ // "{"
+
+	for hadComma := true; ; {
+		comments := p.skipSpace()
+
+		if p.peek() == '}' {
+			p.pos++
+			return annotateLast(obj, comments), nil
+		}
+		if !hadComma {
+			return obj, errors.New("expected ',' or '}' after object member")
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return obj, err
+		}
+
+		p.skipSpace()
+		if p.peek() != ':' {
+			return obj, fmt.Errorf("expected ':' after object key %q", key)
+		}
+		p.pos++
+
+		value, err := p.parseValue()
+		if err != nil {
+			if value != nil {
+				obj = append(obj, Member{Key: key, Value: value})
+			}
+			return obj, err
+		}
+
+		var trailing []string
+		trailing, hadComma = p.parseSeparator()
+		if comments = append(comments, trailing...); len(comments) > 0 {
+			value = annotate(value, comments)
+		}
+		obj = append(obj, Member{Key: key, Value: value})
+	}
+}
+
+func (p *lenientParser) parseArray() (interface{}, error) {
+	arr := []interface{}{}
+	p.pos++ // "["
+
+	for hadComma := true; ; {
+		comments := p.skipSpace()
+
+		if p.peek() == ']' {
+			p.pos++
+			if len(comments) > 0 && len(arr) > 0 {
+				arr[len(arr)-1] = annotate(arr[len(arr)-1], comments)
+			}
+			return arr, nil
+		}
+		if !hadComma {
+			return arr, errors.New("expected ',' or ']' after array element")
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			if value != nil {
+				arr = append(arr, value)
+			}
+			return arr, err
+		}
+
+		var trailing []string
+		trailing, hadComma = p.parseSeparator()
+		if comments = append(comments, trailing...); len(comments) > 0 {
+			value = annotate(value, comments)
+		}
+		arr = append(arr, value)
+	}
+}
+
+// parseSeparator reads the optional comma after a member or element. It
+// returns the comments that follow on the same line, and whether there
+// was a comma.
+func (p *lenientParser) parseSeparator() ([]string, bool) {
+	comments := p.skipLineSpace()
+	if p.peek() != ',' {
+		return comments, false
+	}
+
+	p.pos++
+	return append(comments, p.skipLineSpace()...), true
+}
+
+func (p *lenientParser) parseKey() (string, error) {
+	if c := p.peek(); c == '"' || c == '\'' {
+		return p.parseString()
+	}
+
+	key := p.parseIdentifier()
+	if key == "" {
+		return "", errors.New("expected object key")
+	}
+	return key, nil
+}
+
+// parseIdentifier reads an unquoted key or a literal like true or NaN
+func (p *lenientParser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRune(p.src[p.pos:])
+		isStart := unicode.IsLetter(r) || r == '_' || r == '$'
+		if !isStart && (p.pos == start || !unicode.IsDigit(r)) {
+			break
+		}
+		p.pos += size
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *lenientParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+
+		case c == '\n':
+			return b.String(), errors.New("unterminated string")
+
+		case c == '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return b.String(), err
+			}
+
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return b.String(), errors.New("unterminated string")
+}
+
+func (p *lenientParser) parseEscape(b *strings.Builder) error {
+	p.pos++ // "\"
+	if p.pos >= len(p.src) {
+		return errors.New("unterminated string")
+	}
+
+	c := p.src[p.pos]
+	p.pos++
+
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'v':
+		b.WriteByte('\v')
+	case '0':
+		b.WriteByte(0)
+	case '\r':
+		// Line continuation, "\r\n" is a single line break
+		if p.peek() == '\n' {
+			p.pos++
+		}
+	case '\n':
+		// Line continuation
+	case 'x':
+		code, err := p.parseHex(2)
+		if err != nil {
+			return err
+		}
+		b.WriteRune(rune(code))
+	case 'u':
+		code, err := p.parseHex(4)
+		if err != nil {
+			return err
+		}
+
+		r := rune(code)
+		if utf16.IsSurrogate(r) && strings.HasPrefix(string(p.src[p.pos:]), "\\u") {
+			p.pos += 2
+			low, err := p.parseHex(4)
+			if err != nil {
+				return err
+			}
+			r = utf16.DecodeRune(r, rune(low))
+		}
+		b.WriteRune(r)
+	default:
+		// JSON5 keeps any other escaped character as is
+		p.pos--
+		r, size := utf8.DecodeRune(p.src[p.pos:])
+		b.WriteRune(r)
+		p.pos += size
+	}
+
+	return nil
+}
+
+func (p *lenientParser) parseHex(digits int) (uint64, error) {
+	if p.pos+digits > len(p.src) {
+		return 0, errors.New("invalid escape sequence")
+	}
+
+	code, err := strconv.ParseUint(string(p.src[p.pos:p.pos+digits]), 16, 32)
+	if err != nil {
+		return 0, errors.New("invalid escape sequence")
+	}
+	p.pos += digits
+	return code, nil
+}
+
+// parseNumber reads a JSON5 number and returns it as a JSON number
+// literal, so it is handled like the numbers of a regular document
+func (p *lenientParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	sign := ""
+	if c := p.peek(); c == '-' || c == '+' {
+		if c == '-' {
+			sign = "-"
+		}
+		p.pos++
+	}
+
+	if word := p.parseIdentifier(); word == "Infinity" || word == "NaN" {
+		return json.Number(sign + word), nil
+	} else if word != "" {
+		p.pos -= len(word)
+	}
+
+	rest := string(p.src[p.pos:])
+	if strings.HasPrefix(rest, "0x") || strings.HasPrefix(rest, "0X") {
+		p.pos += 2
+		digits := p.scan("0123456789abcdefABCDEF")
+		n, ok := new(big.Int).SetString(digits, 16)
+		if !ok {
+			p.pos = start
+			return nil, errors.New("invalid hexadecimal number")
+		}
+		return json.Number(sign + n.String()), nil
+	}
+
+	integer := p.scan("0123456789")
+	hasDot := p.peek() == '.'
+	fraction := ""
+	if hasDot {
+		p.pos++
+		fraction = p.scan("0123456789")
+	}
+	if integer == "" && fraction == "" {
+		p.pos = start
+		return nil, errors.New("invalid number")
+	}
+
+	exponent := ""
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		expSign := ""
+		if c := p.peek(); c == '-' || c == '+' {
+			expSign = string(c)
+			p.pos++
+		}
+		digits := p.scan("0123456789")
+		if digits == "" {
+			p.pos = start
+			return nil, errors.New("invalid number exponent")
+		}
+		exponent = "e" + expSign + digits
+	}
+
+	// Write the number in the JSON form: "0.5" for ".5" and "5.0" for "5."
+	if integer == "" {
+		integer = "0"
+	}
+	literal := sign + integer
+	if hasDot {
+		if fraction == "" {
+			fraction = "0"
+		}
+		literal += "." + fraction
+	}
+	return json.Number(literal + exponent), nil
+}
+
+func (p *lenientParser) scan(chars string) string {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte(chars, p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// annotate adds comments to the note of a value
+func annotate(value interface{}, comments []string) interface{} {
+	value, note := unwrapValue(value)
+	notes := comments
+	if note != "" {
+		notes = append([]string{note}, comments...)
+	}
+	return Annotated{Value: value, Note: strings.Join(notes, " ")}
+}
+
+// annotateLast adds the comments at the end of an object to its last member
+func annotateLast(obj Object, comments []string) Object {
+	if len(comments) > 0 && len(obj) > 0 {
+		last := &obj[len(obj)-1]
+		last.Value = annotate(last.Value, comments)
+	}
+	return obj
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLenient(t *testing.T) {
+	input := `// Compiler settings
+{
+  /* Target */
+  compilerOptions: {
+    target: 'es2020', // language level
+    strict: true,
+    paths: {"@/*": ["src/*",],},
+  },
+  "include": ['src', "test",],
+}
+`
+	data, err := ParseLenient([]byte(input))
+	assert.NoError(t, err)
+
+	tree := BuildTree(data, "", nil)
+	assert.Equal(t, []string{"compilerOptions", "include"}, tree.GetChildren(""))
+	assert.Equal(t, "es2020", mustGetValue(t, tree, "compilerOptions.target"))
+	assert.Equal(t, true, mustGetValue(t, tree, "compilerOptions.strict"))
+	assert.Equal(t, "src/*", mustGetValue(t, tree, "compilerOptions.paths.@/*[0]"))
+	assert.Equal(t, "test", mustGetValue(t, tree, "include[1]"))
+
+	// Comments are kept as notes on the nodes
+	assert.Equal(t, "// Compiler settings", tree.Node("").Note)
+	assert.Equal(t, "/* Target */", tree.Node("compilerOptions").Note)
+	assert.Equal(t, "// language level", tree.Node("compilerOptions.target").Note)
+	assert.Equal(t, "", tree.Node("compilerOptions.strict").Note)
+}
+
+func TestParseLenient_NullNotes(t *testing.T) {
+	input := `[
+  // not set
+  null,
+  /* inline */ null
+]`
+	data, err := ParseLenient([]byte(input))
+	assert.NoError(t, err)
+
+	tree := BuildTree(data, "", nil)
+	assert.Nil(t, mustGetValue(t, tree, "[0]"))
+	assert.Equal(t, "// not set", tree.Node("[0]").Note)
+	assert.Equal(t, "/* inline */", tree.Node("[1]").Note)
+}
+
+func TestParseLenient_Values(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"hex", `0x1F`, json.Number("31")},
+		{"leading plus", `+1`, json.Number("1")},
+		{"leading dot", `.5`, json.Number("0.5")},
+		{"trailing dot", `-5.`, json.Number("-5.0")},
+		{"exponent", `1E3`, json.Number("1e3")},
+		{"infinity", `-Infinity`, json.Number("-Infinity")},
+		{"nan", `NaN`, json.Number("NaN")},
+		{"single quotes", `'it\'s "quoted"'`, `it's "quoted"`},
+		{"escapes", `"\x41é\n\t"`, "Aé\n\t"},
+		{"line continuation", "'a\\\nb'", "ab"},
+		{"null", `null`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ParseLenient([]byte(tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestParseLenient_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column int
+	}{
+		{"missing comma", "{\n  a: 1\n  b: 2\n}", 3, 3},
+		{"double comma", "[1,, 2]", 1, 4},
+		{"unterminated string", "{a: 'x\n}", 1, 7},
+		{"unterminated comment", "{a: 1 /* x }", 1, 7},
+		{"trailing data", "{} x", 1, 4},
+		{"too deep", strings.Repeat("[", maxNestingDepth+1), 1, maxNestingDepth + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLenient([]byte(tt.input))
+
+			var parseErr *ParseError
+			assert.ErrorAs(t, err, &parseErr)
+			parseErr.Locate([]byte(tt.input))
+			assert.Equal(t, tt.line, parseErr.Line)
+			assert.Equal(t, tt.column, parseErr.Column)
+		})
+	}
+}
+
+func TestParseLenient_RenderNotes(t *testing.T) {
+	SetCurrentTheme("nocolor")
+
+	data, err := ParseLenient([]byte("{\n  a: 1, // one\n}"))
+	assert.NoError(t, err)
+	tree := BuildTree(data, "", nil)
+
+	var lines []string
+	for _, line := range tree.PrintAsJSON2() {
+		lines = append(lines, RenderLine(line, false))
+	}
+	assert.Equal(t, "{\n  \"a\": 1 // one\n}", strings.Join(lines, "\n"))
+}
+
+func TestIsLenientFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"config.jsonc", true},
+		{"data.JSON5", true},
+		{"project/tsconfig.json", true},
+		{"tsconfig.base.json", true},
+		{".devcontainer/devcontainer.json", true},
+		{".vscode/settings.json", true},
+		{"project/.vscode/launch.json", true},
+		{"settings.json", false},
+		{"app/config/settings.json", false},
+		{"package.json", false},
+		{"data.json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isLenientFile(tt.path))
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+// lineIndex keeps the lines of the document in order, and which of them
+// are shown; the lines inside folded nodes are not. It is a treap ordered
+// by the position of the lines, whose nodes count the lines and the shown
+// lines under them. The line at a position or at a position on screen,
+// and the position of a line, are found in O(log n). Lines are inserted
+// or removed anywhere in O(log n) plus the number of lines, so unfolding
+// or decoding a node does not number the whole document again.
+//
+// A line is referred to by a handle that stays the same when lines are
+// added or removed before it. Handle 0 is no line.
+type lineIndex struct {
+	nodes []lineNode
+	root  int32
+	spine []int32 // right spine from the root, where push adds lines
+	moved bool    // the spine must be found again after an insert or a remove
+	seed  uint32
+}
+
+type lineNode struct {
+	ref                 lineRef
+	left, right, parent int32
+	size, count         int32 // lines and shown lines in the subtree
+	priority            uint32
+	shown               bool
+}
+
+// add creates a line that is not in the document yet, see push and
+// insert, and returns its handle
+func (li *lineIndex) add(ref lineRef, shown bool) int32 {
+	if len(li.nodes) == 0 {
+		// Handle 0 is no line, its subtree is empty
+		li.nodes = append(li.nodes, lineNode{})
+		li.seed = 2463534242
+	}
+
+	// xorshift, the priorities only need to look random
+	li.seed ^= li.seed << 13
+	li.seed ^= li.seed >> 17
+	li.seed ^= li.seed << 5
+
+	li.nodes = append(li.nodes, lineNode{ref: ref, size: 1, priority: li.seed, shown: shown})
+	if shown {
+		li.nodes[len(li.nodes)-1].count = 1
+	}
+	return int32(len(li.nodes) - 1)
+}
+
+// push adds a line at the end of the document, in O(1) amortized
+func (li *lineIndex) push(h int32) {
+	if li.moved {
+		li.spine = li.spine[:0]
+		for x := li.root; x != 0; x = li.nodes[x].right {
+			li.spine = append(li.spine, x)
+		}
+		li.moved = false
+	}
+	li.root, li.spine = li.appendTo(li.root, li.spine, h)
+}
+
+// appendTo adds the line h after the last line of the treap at root,
+// whose right spine is spine, and returns the new root and spine
+func (li *lineIndex) appendTo(root int32, spine []int32, h int32) (int32, []int32) {
+	n := li.nodes
+	x := &n[h]
+
+	// The lines of the spine with a lower priority go under the new line
+	var last int32
+	for len(spine) > 0 && n[spine[len(spine)-1]].priority < x.priority {
+		last = spine[len(spine)-1]
+		spine = spine[:len(spine)-1]
+	}
+	x.left, x.right, x.parent = last, 0, 0
+	li.update(h)
+	li.setParent(last, h)
+
+	if len(spine) > 0 {
+		p := spine[len(spine)-1]
+		n[p].right, x.parent = h, p
+	} else {
+		root = h
+	}
+	for _, s := range spine {
+		n[s].size++
+		if x.shown {
+			n[s].count++
+		}
+	}
+	return root, append(spine, h)
+}
+
+// insert adds the lines, created by add, before the line at position pos
+func (li *lineIndex) insert(pos int, lines []int32) {
+	var root int32
+	var spine []int32
+	for _, h := range lines {
+		root, spine = li.appendTo(root, spine, h)
+	}
+
+	left, right := li.split(li.root, int32(pos))
+	li.root = li.merge(li.merge(left, root), right)
+	li.setParent(li.root, 0)
+	li.moved = true
+}
+
+// remove removes the lines from position from to position to, excluded.
+// Their handles are not used again.
+func (li *lineIndex) remove(from int, to int) {
+	left, rest := li.split(li.root, int32(from))
+	_, right := li.split(rest, int32(to-from))
+	li.root = li.merge(left, right)
+	li.setParent(li.root, 0)
+	li.moved = true
+}
+
+// split cuts the treap at t into its first k lines and the others. The
+// roots returned have no parent.
+func (li *lineIndex) split(t int32, k int32) (int32, int32) {
+	if t == 0 {
+		return 0, 0
+	}
+	n := li.nodes
+	n[t].parent = 0
+
+	if left := n[t].left; n[left].size >= k {
+		l, r := li.split(left, k)
+		n[t].left = r
+		li.setParent(r, t)
+		li.update(t)
+		return l, t
+	}
+
+	l, r := li.split(n[t].right, k-n[n[t].left].size-1)
+	n[t].right = l
+	li.setParent(l, t)
+	li.update(t)
+	return t, r
+}
+
+// merge joins two treaps, the lines of a go before the lines of b
+func (li *lineIndex) merge(a int32, b int32) int32 {
+	if a == 0 || b == 0 {
+		return a + b
+	}
+	n := li.nodes
+
+	if n[a].priority > n[b].priority {
+		r := li.merge(n[a].right, b)
+		n[a].right = r
+		li.setParent(r, a)
+		li.update(a)
+		return a
+	}
+
+	l := li.merge(a, n[b].left)
+	n[b].left = l
+	li.setParent(l, b)
+	li.update(b)
+	return b
+}
+
+func (li *lineIndex) setParent(child int32, parent int32) {
+	if child != 0 {
+		li.nodes[child].parent = parent
+	}
+}
+
+// update counts the lines under t again from its children
+func (li *lineIndex) update(t int32) {
+	n := li.nodes
+	x := &n[t]
+	x.size = 1 + n[x.left].size + n[x.right].size
+	x.count = n[x.left].count + n[x.right].count
+	if x.shown {
+		x.count++
+	}
+}
+
+// set shows or hides a line
+func (li *lineIndex) set(h int32, shown bool) {
+	if h == 0 || li.nodes[h].shown == shown {
+		return
+	}
+	li.nodes[h].shown = shown
+
+	delta := int32(1)
+	if !shown {
+		delta = -1
+	}
+	for x := h; x != 0; x = li.nodes[x].parent {
+		li.nodes[x].count += delta
+	}
+}
+
+// ref returns the node of a line, and whether it is its closing line
+func (li *lineIndex) ref(h int32) lineRef {
+	return li.nodes[h].ref
+}
+
+// isShown reports whether the line is shown
+func (li *lineIndex) isShown(h int32) bool {
+	return h != 0 && li.nodes[h].shown
+}
+
+// line returns the position of a line in the document, false when it was
+// removed or not added yet
+func (li *lineIndex) line(h int32) (int, bool) {
+	line, _, found := li.position(h)
+	return line, found
+}
+
+// virtual returns the position on screen of a line, when it is shown
+func (li *lineIndex) virtual(h int32) (int, bool) {
+	_, virtual, found := li.position(h)
+	return virtual, found && li.isShown(h)
+}
+
+// position returns the number of lines and of shown lines before h
+func (li *lineIndex) position(h int32) (int, int, bool) {
+	if h == 0 || int(h) >= len(li.nodes) {
+		return 0, 0, false
+	}
+	n := li.nodes
+
+	line, virtual := n[n[h].left].size, n[n[h].left].count
+	x := h
+	for p := n[x].parent; p != 0; x, p = p, n[p].parent {
+		if n[p].right == x {
+			line += n[n[p].left].size + 1
+			virtual += n[n[p].left].count
+			if n[p].shown {
+				virtual++
+			}
+		}
+	}
+	return int(line), int(virtual), x == li.root
+}
+
+// at returns the line at a position of the document, which must be
+// between 0 and len()-1
+func (li *lineIndex) at(line int) int32 {
+	n := li.nodes
+	k := int32(line)
+	t := li.root
+	for t != 0 {
+		left := n[t].left
+		switch {
+		case k < n[left].size:
+			t = left
+		case k == n[left].size:
+			return t
+		default:
+			k -= n[left].size + 1
+			t = n[t].right
+		}
+	}
+	return 0
+}
+
+// real returns the position in the document of a line on screen, which
+// must be between 0 and count()-1
+func (li *lineIndex) real(virtual int) int {
+	n := li.nodes
+	v := int32(virtual)
+	line := int32(0)
+	t := li.root
+	for t != 0 {
+		left := n[t].left
+		if v < n[left].count {
+			t = left
+			continue
+		}
+		v -= n[left].count
+		line += n[left].size
+		if n[t].shown {
+			if v == 0 {
+				return int(line)
+			}
+			v--
+		}
+		line++
+		t = n[t].right
+	}
+	return int(line)
+}
+
+// len returns the number of lines of the document
+func (li *lineIndex) len() int {
+	if li.root == 0 {
+		return 0
+	}
+	return int(li.nodes[li.root].size)
+}
+
+// count returns the number of lines shown
+func (li *lineIndex) count() int {
+	if li.root == 0 {
+		return 0
+	}
+	return int(li.nodes[li.root].count)
+}
+
+// eachShown calls fn with the shown lines and their position in the
+// document, in order
+func (li *lineIndex) eachShown(fn func(h int32, line int)) {
+	var walk func(t int32, line int32)
+	walk = func(t int32, line int32) {
+		for t != 0 && li.nodes[t].count > 0 {
+			left := li.nodes[t].left
+			walk(left, line)
+			line += li.nodes[left].size
+			if li.nodes[t].shown {
+				fn(t, int(line))
+			}
+			line++
+			t = li.nodes[t].right
+		}
+	}
+	walk(li.root, 0)
+}
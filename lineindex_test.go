@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var li lineIndex
+	var lines []int32 // the handles in document order
+	add := func() int32 {
+		return li.add(lineRef{node: NodeID(rng.Intn(1000))}, rng.Intn(3) > 0)
+	}
+	for range 100 {
+		h := add()
+		li.push(h)
+		lines = append(lines, h)
+	}
+
+	check := func() {
+		t.Helper()
+		var real []int
+		for line, h := range lines {
+			assert.Equal(t, h, li.at(line))
+			position, found := li.line(h)
+			assert.True(t, found)
+			assert.Equal(t, line, position)
+
+			virtual, found := li.virtual(h)
+			assert.Equal(t, li.isShown(h), found)
+			if li.isShown(h) {
+				assert.Equal(t, len(real), virtual)
+				real = append(real, line)
+			}
+		}
+		assert.Equal(t, len(lines), li.len())
+		assert.Equal(t, len(real), li.count())
+		for virtual, line := range real {
+			assert.Equal(t, line, li.real(virtual))
+		}
+
+		var shown []int
+		li.eachShown(func(h int32, line int) {
+			assert.Equal(t, lines[line], h)
+			shown = append(shown, line)
+		})
+		assert.Equal(t, real, shown)
+	}
+	check()
+
+	var removed []int32
+	for range 200 {
+		switch rng.Intn(4) {
+		case 0:
+			li.set(lines[rng.Intn(len(lines))], rng.Intn(2) == 0)
+		case 1:
+			// Lines are added in the middle
+			pos := rng.Intn(len(lines) + 1)
+			added := make([]int32, rng.Intn(5))
+			for i := range added {
+				added[i] = add()
+			}
+			li.insert(pos, added)
+			lines = slices.Insert(lines, pos, added...)
+		case 2:
+			from := rng.Intn(len(lines))
+			to := min(len(lines), from+rng.Intn(5))
+			li.remove(from, to)
+			removed = append(removed, lines[from:to]...)
+			lines = slices.Delete(lines, from, to)
+		case 3:
+			// and at the end after an insert or a remove
+			h := add()
+			li.push(h)
+			lines = append(lines, h)
+		}
+	}
+	check()
+
+	// The removed lines have no position
+	for _, h := range removed {
+		_, found := li.line(h)
+		assert.False(t, found)
+	}
+}
@@ -22,7 +22,7 @@ type LineMetadata struct {
 	LineNumber     int
 	LineType       LineType
 	Content        string
-	NodePath       string
+	NodeID         NodeID
 	NodeType       NodeType
 	Key            string
 	Value          interface{}
@@ -32,6 +32,9 @@ type LineMetadata struct {
 	BracketChar    string // "{", "}", "[", "]"
 	IsArrayElement bool
 	IsLastChild    bool // for comma handling
+	Change         ChangeKind
+	Note           string // shown dimmed after the line, e.g. a comment
+	Duplicate      bool   // the key appears more than once in its object
 }
 
 type VisibleLines struct {
@@ -41,10 +44,12 @@ type VisibleLines struct {
 	linesOnScreen []line
 }
 
+// VisibleLines2 holds the lines on screen. They are built from the tree
+// when the window moves, the rest of the document is never rendered.
 type VisibleLines2 struct {
 	firstLine     int
 	total         int
-	content       []LineMetadata
+	tree          *JSONTree
 	linesOnScreen []LineMetadata
 }
 
@@ -55,9 +60,9 @@ func NewVisibleLines(firstLine int, total int, content string) *VisibleLines {
 	return vl
 }
 
-func NewVisibleLines2(firstLine int, total int, content []LineMetadata) *VisibleLines2 {
+func NewVisibleLines2(firstLine int, total int, tree *JSONTree) *VisibleLines2 {
 	vl := &VisibleLines2{}
-	vl.UpdateContent2(content)
+	vl.UpdateContent2(tree)
 	vl.UpdateVisibleLines2(firstLine, total)
 	return vl
 }
@@ -72,15 +77,19 @@ func (vl *VisibleLines) UpdateContent(content string) {
 	}
 }
 
-func (vl *VisibleLines2) UpdateContent2(content []LineMetadata) {
-	// clear slice
-	vl.content = vl.content[:0]
+// UpdateContent2 sets the tree the lines come from. The lines on screen
+// are built again by UpdateVisibleLines2.
+func (vl *VisibleLines2) UpdateContent2(tree *JSONTree) {
+	vl.tree = tree
+}
 
-	// equivalent to:
-	// for _,line := range content {
-	// 	vl.content = append(vl.content, line)
-	// }
-	vl.content = append(vl.content, content...)
+// Len returns the number of lines of the document with the folded nodes
+// left out
+func (vl *VisibleLines2) Len() int {
+	if vl.tree == nil {
+		return 0
+	}
+	return vl.tree.VisibleLen()
 }
 
 func (vl *VisibleLines) UpdateVisibleLines(firstLine int, total int) {
@@ -105,10 +114,8 @@ func (vl *VisibleLines2) UpdateVisibleLines2(firstLine int, total int) {
 	// clear slice
 	vl.linesOnScreen = vl.linesOnScreen[:0]
 
-	// copy the lines that should be on the screen
-	for _, line := range vl.content {
-		if line.LineNumber >= vl.firstLine && len(vl.linesOnScreen) < vl.total {
-			vl.linesOnScreen = append(vl.linesOnScreen, line)
-		}
+	// build the lines that should be on the screen
+	for i := max(vl.firstLine, 0); i < vl.Len() && len(vl.linesOnScreen) < vl.total; i++ {
+		vl.linesOnScreen = append(vl.linesOnScreen, vl.tree.Line(i))
 	}
 }
@@ -55,50 +55,37 @@ func TestNewVisibleLines2(t *testing.T) {
 		name      string
 		firstLine int
 		total     int
-		content   []LineMetadata
+		content   []interface{}
 		expected  []string
 	}{
 		{
 			"all lines visible",
 			0,
 			10,
-			[]LineMetadata{
-				{LineNumber: 0, Content: "line0"},
-				{LineNumber: 1, Content: "line1"},
-				{LineNumber: 2, Content: "line2"}},
-			[]string{"line0", "line1", "line2"},
+			[]interface{}{"line0", "line1"},
+			[]string{"[", "line0", "line1", "]"},
 		},
 		{
 			"two lines visible",
 			1,
 			2,
-			[]LineMetadata{
-				{LineNumber: 0, Content: "line0"},
-				{LineNumber: 1, Content: "line1"},
-				{LineNumber: 2, Content: "line2"}},
-			[]string{"line1", "line2"},
+			[]interface{}{"line0", "line1"},
+			[]string{"line0", "line1"},
 		},
 		{
 			"four lines visible",
 			2,
 			4,
-			[]LineMetadata{
-				{LineNumber: 0, Content: "line0"},
-				{LineNumber: 1, Content: "line1"},
-				{LineNumber: 2, Content: "line2"},
-				{LineNumber: 3, Content: "line3"},
-				{LineNumber: 4, Content: "line4"},
-				{LineNumber: 5, Content: "line5"},
-				{LineNumber: 6, Content: "line6"},
-				{LineNumber: 7, Content: "line7"},
-				{LineNumber: 8, Content: "line7"}},
-			[]string{"line2", "line3", "line4", "line5"},
+			[]interface{}{"line0", "line1", "line2", "line3",
+				"line4", "line5", "line6", "line7"},
+			[]string{"line1", "line2", "line3", "line4"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vl := NewVisibleLines2(tt.firstLine, tt.total, tt.content)
+			vl := NewVisibleLines2(tt.firstLine, tt.total,
+				BuildTree(tt.content, "", nil))
 
 			// extract the Content of each LineMetadata struct
 			var actual []string
@@ -110,3 +97,28 @@ func TestNewVisibleLines2(t *testing.T) {
 		})
 	}
 }
+
+func TestVisibleLines2_Folded(t *testing.T) {
+	tree := BuildTree(map[string]interface{}{
+		"a": []interface{}{1, 2},
+		"b": 3,
+	}, "", nil)
+	vl := NewVisibleLines2(0, 10, tree)
+	assert.Equal(t, 7, vl.Len())
+
+	tree.Collapse("a")
+	vl.UpdateVisibleLines2(0, 10)
+	assert.Equal(t, 4, vl.Len())
+	assert.Equal(t, []int{0, 1, 2, 3}, lineNumbers(vl.linesOnScreen))
+	assert.Equal(t, "b", vl.linesOnScreen[2].Key)
+	assert.True(t, vl.linesOnScreen[1].IsCollapsed)
+}
+
+// lineNumbers returns the virtual line numbers of the lines
+func lineNumbers(lines []LineMetadata) []int {
+	var numbers []int
+	for _, line := range lines {
+		numbers = append(numbers, line.LineNumber)
+	}
+	return numbers
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Maximum number of top-level nodes added to the tree in one update
+const loadBatchSize = 1000
+
+// How often the progress of the loader is shown
+const progressInterval = 100 * time.Millisecond
+
+// Width of the progress bar in the status bar
+const progressBarWidth = 20
+
+// loadBatch is a part of a JSON input read by readTopLevel. The first
+// value is opened as a document whose members arrive in batches; when a
+// second value follows, the tree is started again as a stream of records.
+type loadBatch struct {
+	opened  bool        // root is the first value of the input
+	root    interface{} // an empty object or array when members follow
+	members []Member    // of the root, elements have no key
+	stream  bool        // the values are records, the tree starts again
+	records []interface{}
+	read    int64 // offset reached in the input
+	done    bool  // the input ended, or err stopped it
+	err     error
+}
+
+// topLevelReader reads the top-level values of a JSON input, the members
+// of the first one as they are read
+type topLevelReader interface {
+	// next returns the first byte of the next value and its offset, or
+	// io.EOF at the end of the input
+	next() (byte, int64, error)
+	// members reads the object or the array at the next byte
+	members(add func(Member) error) error
+	// value reads the next value, or the part before an error
+	value() (interface{}, error)
+	// at returns the offset reached in the input
+	at() int64
+	// record returns the first value again, as a record of a stream
+	record(start int64, end int64, first byte) (interface{}, error)
+}
+
+// errLoadStopped stops readTopLevel when the batches are not wanted
+// anymore
+var errLoadStopped = errors.New("loading stopped")
+
+// readTopLevel reads the top-level values of r and passes them to emit in
+// batches, the last one has done set. It stops when emit returns false.
+func readTopLevel(r topLevelReader, opts options, emit func(loadBatch) bool) {
+	var batch loadBatch
+	send := func() bool {
+		batch.read = r.at()
+		ok := emit(batch)
+		batch = loadBatch{}
+		return ok
+	}
+
+	count := 0
+	opened := false
+	var first interface{} // a first value that is not an object or array
+	var start, end int64  // of an opened first value
+	var bracket byte
+
+	var err error
+	for {
+		var c byte
+		var offset int64
+		if c, offset, err = r.next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+
+		if count == 0 && !opts.ndjson && (c == '{' || c == '[') {
+			// The members are shown while the rest is read
+			batch.opened, batch.root = true, Object{}
+			if c == '[' {
+				batch.root = []interface{}{}
+			}
+			opened, start, bracket = true, offset, c
+			err = r.members(func(member Member) error {
+				batch.members = append(batch.members, member)
+				if len(batch.members) == loadBatchSize && !send() {
+					return errLoadStopped
+				}
+				return nil
+			})
+			count++
+			end = r.at()
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		var value interface{}
+		value, err = r.value()
+		if err != nil && value == nil {
+			break
+		}
+		// Keep the part of a value read before an error
+		count++
+
+		switch {
+		case opts.ndjson:
+			batch.stream = batch.stream || count == 1
+			batch.records = append(batch.records, value)
+		case count == 1:
+			first = value
+		case count == 2:
+			// The input is a stream, its first value is a record too
+			batch.stream = true
+			if opened {
+				if first, err = r.record(start, end, bracket); err != nil {
+					break
+				}
+			}
+			batch.records = append(batch.records, first, value)
+		default:
+			batch.records = append(batch.records, value)
+		}
+
+		if err != nil {
+			break
+		}
+		if len(batch.records) >= loadBatchSize && !send() {
+			return
+		}
+	}
+	if err == errLoadStopped {
+		return
+	}
+
+	if count == 1 && !opened && !opts.ndjson {
+		batch.opened, batch.root = true, first
+	}
+	if count == 0 && err == nil {
+		err = &ParseError{Err: errors.New("no JSON value in input")}
+	}
+	batch.done, batch.err = true, err
+	send()
+}
+
+// applyBatch adds a batch read by readTopLevel to the tree
+func (jt *JSONTree) applyBatch(batch loadBatch) {
+	if batch.opened {
+		jt.openDocument(batch.root)
+	}
+	for _, member := range batch.members {
+		jt.appendMember(member)
+	}
+	if batch.stream {
+		// The nodes of the first value are replaced by its record,
+		// nested values are still read from the same input
+		*jt = JSONTree{source: jt.source}
+	}
+	for _, record := range batch.records {
+		jt.AppendRecord(record)
+	}
+	if batch.done {
+		jt.closeDocument()
+	}
+}
+
+// openDocument adds the root of a document. The members of an object or
+// an array are added by appendMember, until closeDocument.
+func (jt *JSONTree) openDocument(value interface{}) {
+	value, note := unwrapValue(value)
+	id := jt.AddChild(noNode, Node{
+		Type:  getNodeType(value),
+		Value: leafValue(value),
+		Key:   "root",
+		Note:  note,
+	})
+	jt.nodes[id].line = jt.addLine(id, false)
+	jt.loading = isContainer(&jt.nodes[id])
+}
+
+// appendMember adds the next member of the root, or its next element
+func (jt *JSONTree) appendMember(member Member) {
+	isArray := jt.nodes[rootID].Type == ArrayType
+	key := member.Key
+	if isArray {
+		index := 0
+		if last := jt.lastChild[rootID]; last != noNode {
+			index = elementIndex(&jt.nodes[last]) + 1
+		}
+		key = fmt.Sprintf("[%d]", index)
+	}
+
+	id := jt.newNode(rootID, member.Value, key, isArray)
+	if isNested(member.Value) {
+		jt.buildChildren(id, member.Value)
+	}
+}
+
+// closeDocument adds the closing line of the root once all its members
+// were added
+func (jt *JSONTree) closeDocument() {
+	if !jt.loading {
+		return
+	}
+	jt.loading = false
+	jt.nodes[rootID].closingLine = jt.addLine(rootID, true)
+	if jt.nodes[rootID].Type != ObjectType {
+		return
+	}
+
+	// The keys of the root are only known now, its duplicates go before
+	// the ones nested in its members
+	counts := make(map[string]int)
+	for child := jt.firstChild[rootID]; child != noNode; child = jt.nextSibling[child] {
+		counts[jt.nodes[child].Key]++
+	}
+	seen := make(map[string]int, len(counts))
+	for child := jt.firstChild[rootID]; child != noNode; child = jt.nextSibling[child] {
+		jt.markDuplicate(child, counts, seen)
+	}
+	delete(jt.index, rootID)
+	slices.SortFunc(jt.Duplicates, func(a, b NodeID) int {
+		return jt.LineNumber(a) - jt.LineNumber(b)
+	})
+}
+
+// jsonReader reads the top-level values of a JSON input in memory
+type jsonReader struct {
+	input []byte
+	dec   *json.Decoder
+}
+
+func newJSONReader(input []byte) *jsonReader {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+	return &jsonReader{input: input, dec: dec}
+}
+
+// parseError returns the error of the decoder at its offset
+func (r *jsonReader) parseError(err error) error {
+	if errors.Is(err, io.EOF) {
+		// The input ended before the value was complete
+		err = io.ErrUnexpectedEOF
+	}
+	return newParseError(r.dec, err)
+}
+
+func (r *jsonReader) next() (byte, int64, error) {
+	if !r.dec.More() {
+		// More also stops at a stray "]" or "}", so make sure the input
+		// really ended
+		if _, err := r.dec.Token(); err != io.EOF {
+			if err == nil {
+				err = errors.New("invalid data after top-level value")
+			}
+			return 0, 0, newParseError(r.dec, err)
+		}
+		return 0, 0, io.EOF
+	}
+
+	// More skipped the whitespace in the buffer of the decoder, not in
+	// its offset
+	offset := r.dec.InputOffset()
+	rest := bytes.TrimLeft(r.input[offset:], " \t\r\n")
+	offset = int64(len(r.input) - len(rest))
+	return rest[0], offset, nil
+}
+
+func (r *jsonReader) members(add func(Member) error) error {
+	tok, err := r.dec.Token()
+	if err != nil {
+		return r.parseError(err)
+	}
+	delim, _ := tok.(json.Delim)
+	if delim != '{' && delim != '[' {
+		return r.parseError(fmt.Errorf("unexpected %v, expected an object or an array", tok))
+	}
+
+	for r.dec.More() {
+		var member Member
+		if delim == '{' {
+			tok, err := r.dec.Token()
+			if err != nil {
+				return r.parseError(err)
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return r.parseError(fmt.Errorf("unexpected object key %v", tok))
+			}
+			member.Key = key
+		}
+
+		value, err := parseValue(r.dec)
+		if err != nil {
+			// Keep the partially read value, if there is one
+			if value != nil {
+				member.Value = value
+				if err := add(member); err != nil {
+					return err
+				}
+			}
+			return r.parseError(err)
+		}
+		member.Value = value
+		if err := add(member); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing bracket
+	if _, err := r.dec.Token(); err != nil {
+		return r.parseError(err)
+	}
+	return nil
+}
+
+func (r *jsonReader) value() (interface{}, error) {
+	value, err := parseValue(r.dec)
+	if err != nil {
+		return value, r.parseError(err)
+	}
+	return value, nil
+}
+
+func (r *jsonReader) at() int64 {
+	return r.dec.InputOffset()
+}
+
+func (r *jsonReader) record(start int64, end int64, _ byte) (interface{}, error) {
+	value, err := ParseJSON(bytes.NewReader(r.input[start:end]))
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Offset += start
+	}
+	return value, err
+}
+
+// loadMsg carries what the loader read since the last update
+type loadMsg struct {
+	loadBatch
+	total      int64       // size of the input, 0 when it is not known
+	file       io.ReaderAt // the big file the nested values are read from
+	source     []byte      // the input, once it was read in memory
+	compressed compression
+	format     string
+	tree       *JSONTree // of the formats that are not read value by value
+}
+
+// loader reads the input in the background and sends it to the model
+type loader struct {
+	ctx   context.Context
+	out   chan<- loadMsg
+	total int64
+	last  time.Time // of the last progress message
+}
+
+// send waits until the model takes msg, it returns false when the
+// loading was canceled
+func (l *loader) send(msg loadMsg) bool {
+	if msg.total == 0 {
+		msg.total = l.total
+	}
+	select {
+	case l.out <- msg:
+		return true
+	case <-l.ctx.Done():
+		return false
+	}
+}
+
+// progress sends the number of bytes read, unless the model is busy or
+// it was sent a moment ago. It returns the error of a canceled loading.
+func (l *loader) progress(read int64) error {
+	if err := l.ctx.Err(); err != nil {
+		return err
+	}
+	if time.Since(l.last) < progressInterval {
+		return nil
+	}
+	l.last = time.Now()
+	select {
+	case l.out <- loadMsg{loadBatch: loadBatch{read: read}, total: l.total}:
+	default:
+	}
+	return nil
+}
+
+// startLoading reads and parses the input in the background, it closes
+// the channel when it is done or ctx is canceled. A big JSON file is only
+// indexed like with openLazy; other JSON input is read in memory, then
+// its top-level values are sent as they are parsed. The other formats
+// are sent as a whole tree.
+func startLoading(ctx context.Context, src io.Reader, path string, opts options) <-chan loadMsg {
+	out := make(chan loadMsg, loadQueueSize)
+	l := &loader{ctx: ctx, out: out}
+	if file, ok := src.(*os.File); ok && path != "" {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			l.total = info.Size()
+		}
+	}
+
+	go func() {
+		defer close(out)
+		if path != "" && isLazyFile(path, opts) {
+			l.index(src.(*os.File), opts)
+		} else {
+			l.load(src, path, opts)
+		}
+	}()
+	return out
+}
+
+// Number of messages the loader can send before the model takes them
+const loadQueueSize = 16
+
+// index indexes a big JSON file
+func (l *loader) index(file *os.File, opts options) {
+	if !l.send(loadMsg{file: file, format: defaultFormat}) {
+		return
+	}
+
+	s := newScanner(io.NewSectionReader(file, 0, l.total), 0)
+	s.onRead = l.progress
+	readTopLevel(s, opts, func(batch loadBatch) bool {
+		return l.send(loadMsg{loadBatch: batch})
+	})
+}
+
+// load reads the input in memory and parses it
+func (l *loader) load(src io.Reader, path string, opts options) {
+	input, compressed, err := readInput(&progressReader{r: src, progress: l.progress})
+	if err != nil {
+		l.send(loadMsg{loadBatch: loadBatch{done: true, err: err}})
+		return
+	}
+
+	// Detect the format from the file name and the content, unless it
+	// was given with --format. Reloads use the same.
+	if opts.format == "" {
+		opts.format = detectFormat(path, input)
+	}
+	msg := loadMsg{source: input, compressed: compressed, format: opts.format,
+		total: int64(len(input))}
+	msg.read = int64(len(input))
+	if opts.format != defaultFormat {
+		// The whole tree at once, on error the part read before it
+		msg.tree, msg.err = loadTree(input, opts)
+		msg.done = true
+		l.send(msg)
+		return
+	}
+	if !l.send(msg) {
+		return
+	}
+
+	l.total = int64(len(input))
+	readTopLevel(newJSONReader(input), opts, func(batch loadBatch) bool {
+		return l.send(loadMsg{loadBatch: batch})
+	})
+}
+
+// progressReader reports the bytes read from r
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	progress func(read int64) error
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if err == nil {
+		err = p.progress(p.n)
+	}
+	return n, err
+}
+
+// waitForLoad returns a command that waits for the next message of the
+// loader
+func waitForLoad(in <-chan loadMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-in
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// applyLoad adds what the loader read to the tree. The cursor stays where
+// it is, the user can browse the top-level nodes while the rest is read.
+func (m model) applyLoad(msg loadMsg) (tea.Model, tea.Cmd) {
+	if m.loads == nil {
+		// The loading was stopped by :run, make sure the loader does
+		// not wait for the model anymore
+		m.stopLoading()
+		return m, nil
+	}
+
+	m.loadRead = msg.read
+	if msg.total > 0 {
+		m.loadTotal = msg.total
+	}
+	if msg.file != nil {
+		m.tree.source = msg.file
+	}
+	if msg.source != nil {
+		m.source = msg.source
+		m.compressed = msg.compressed
+	}
+	if msg.format != "" {
+		m.opts.format = msg.format
+	}
+	if msg.tree != nil {
+		m.tree = msg.tree
+	}
+	m.tree.applyBatch(msg.loadBatch)
+
+	if m.ready {
+		m.visibleLines2.UpdateContent2(m.tree)
+		m.cursorY = min(m.cursorY, max(m.visibleLines2.Len()-1, 0))
+		if m.statusBar == "" {
+			m.updateCurrentPath()
+		}
+		m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
+			m.visibleLines2.total)
+	}
+
+	if !msg.done {
+		return m, waitForLoad(m.loads)
+	}
+
+	m.stopLoading()
+
+	var parseErr *ParseError
+	switch {
+	case msg.err != nil && errors.As(msg.err, &parseErr) && m.source != nil:
+		// Like at startup with a file read in memory, the error screen
+		// shows where the input stopped being valid
+		parseErr.Locate(m.source)
+		m.parseErr = parseErr
+		m.mode = ParseFailed
+	case msg.err != nil:
+		m.statusBar = errorStyle.Render("Error: " + msg.err.Error())
+	default:
+		if summary := summarizeDuplicates(m.tree); summary != "" {
+			m.statusBar = summary
+		}
+	}
+	return m, m.watch()
+}
+
+// stopLoading stops the loader, the rest of the input is not read. It is
+// the only way to drop m.loads: a loader that is not canceled would block
+// on its next message.
+func (m *model) stopLoading() {
+	if m.cancelLoad != nil {
+		m.cancelLoad()
+	}
+	m.loads = nil
+}
+
+// loadProgress describes how much of the input was read, for the status
+// bar: a bar when the size of the input is known, and the nodes built
+func (m model) loadProgress() string {
+	var s string
+	if m.loadTotal > 0 {
+		filled := int(min(m.loadRead*progressBarWidth/m.loadTotal, progressBarWidth))
+		s = fmt.Sprintf("%s%s %d%% %s / %s",
+			strings.Repeat("█", filled), strings.Repeat("░", progressBarWidth-filled),
+			min(m.loadRead*100/m.loadTotal, 100),
+			formatSize(m.loadRead), formatSize(m.loadTotal))
+	} else {
+		s = formatSize(m.loadRead)
+	}
+	return fmt.Sprintf("Loading %s · %d nodes", s, m.tree.Len())
+}
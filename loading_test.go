@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadInBatches builds the tree of a JSON input from the batches of
+// readTopLevel, like the model does while it is loaded
+func loadInBatches(input string, opts options) (*JSONTree, error) {
+	tree := NewJSONTree()
+	var err error
+	readTopLevel(newJSONReader([]byte(input)), opts, func(batch loadBatch) bool {
+		tree.applyBatch(batch)
+		err = batch.err
+		return true
+	})
+	return tree, err
+}
+
+func TestReadTopLevel_SameTree(t *testing.T) {
+	var members []string
+	for i := range 2500 {
+		members = append(members, fmt.Sprintf(`"k%d": [%d]`, i%1200, i))
+	}
+
+	inputs := []struct {
+		input string
+		opts  options
+	}{
+		{input: `{"a": 1, "b": {"c": [true, null]}, "a": 2}`},
+		{input: `[1, [2, 3], {"x": "y"}]`},
+		{input: "{" + strings.Join(members, ", ") + "}"},
+		{input: `"just a string"`},
+		{input: `null`},
+		{input: "{\"n\": 1}\n{\"n\": 2}\n3\n"},
+		{input: "1 2 3"},
+		{input: `{"a": 1}`, opts: options{ndjson: true}},
+		{input: `{}`},
+		{input: `[]`},
+		{input: ``},
+		{input: `{"a": 1, "b": [2, `},
+		{input: `{"a": 1} {"b": tru}`},
+		{input: `{"a": 1}]`},
+		{input: `[1, 2] x`},
+	}
+	for _, test := range inputs {
+		want, wantErr := loadTree([]byte(test.input), test.opts)
+		tree, err := loadInBatches(test.input, test.opts)
+
+		assert.Equal(t, wantErr, err, test.input)
+		if want == nil {
+			assert.Equal(t, 0, tree.Len(), test.input)
+			continue
+		}
+		assert.Equal(t, want.PrintAsJSON2(), tree.PrintAsJSON2(), test.input)
+		assert.Equal(t, want.GetAllPaths(), tree.GetAllPaths(), test.input)
+		assert.Equal(t, want.Duplicates, tree.Duplicates, test.input)
+		assert.Equal(t, want.Stream, tree.Stream, test.input)
+	}
+}
+
+func TestReadTopLevel_Batches(t *testing.T) {
+	var members []string
+	for i := range 2500 {
+		members = append(members, fmt.Sprintf("%d", i))
+	}
+	input := "[" + strings.Join(members, ",") + "]"
+
+	// The members arrive while the document is read, the closing line
+	// is added at the end
+	tree := NewJSONTree()
+	var sizes []int
+	readTopLevel(newJSONReader([]byte(input)), options{}, func(batch loadBatch) bool {
+		tree.applyBatch(batch)
+		sizes = append(sizes, tree.VisibleLen())
+		return true
+	})
+	assert.Equal(t, []int{1001, 2001, 2502}, sizes)
+
+	// Nothing more is read when the batches are not wanted
+	batches := 0
+	readTopLevel(newJSONReader([]byte(input)), options{}, func(loadBatch) bool {
+		batches++
+		return false
+	})
+	assert.Equal(t, 1, batches)
+}
+
+func TestJSONTree_FoldWhileLoading(t *testing.T) {
+	tree := NewJSONTree()
+	tree.applyBatch(loadBatch{opened: true, root: Object{}, members: []Member{
+		{Key: "a", Value: Object{{Key: "b", Value: 1}}},
+	}})
+
+	// The root has no closing line yet, its members can be folded
+	tree.Collapse("")
+	assert.False(t, tree.IsCollapsed(""))
+	tree.Collapse("a")
+	assert.Equal(t, 2, tree.VisibleLen())
+
+	tree.applyBatch(loadBatch{members: []Member{{Key: "c", Value: 2}}, done: true})
+	assert.Equal(t, []string{"{", "a", "2", "}"}, lineContents(tree))
+}
+
+// lineContents returns the content of the lines on screen
+func lineContents(tree *JSONTree) []string {
+	var contents []string
+	for _, line := range tree.PrintAsJSON2() {
+		contents = append(contents, line.Content)
+	}
+	return contents
+}
+
+// newLoadingModel returns a model whose input is still loaded
+func newLoadingModel(height int) (model, *bool) {
+	loads := make(chan loadMsg, 10)
+	canceled := false
+	m := model{tree: NewJSONTree(), loads: loads, cancelLoad: func() { canceled = true }}
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: height})
+	return updated.(model), &canceled
+}
+
+func TestApplyLoad(t *testing.T) {
+	m, canceled := newLoadingModel(10)
+
+	updated, cmd := m.Update(loadMsg{loadBatch: loadBatch{read: 50}, total: 200})
+	m = updated.(model)
+	assert.NotNil(t, cmd, "Wait for the next message")
+	assert.Contains(t, m.UpdateStatusBar(), "25% 50 B / 200 B")
+
+	// The top-level nodes can be browsed before the input is read
+	updated, _ = m.Update(loadMsg{loadBatch: loadBatch{opened: true, root: Object{},
+		members: []Member{{Key: "a", Value: 1}, {Key: "b", Value: 2}}, read: 100}})
+	m = updated.(model)
+	assert.Contains(t, m.UpdateStatusBar(), "3 nodes")
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	m = updated.(model)
+	assert.Equal(t, ".a  /a", m.currentPath)
+
+	updated, cmd = m.Update(loadMsg{loadBatch: loadBatch{
+		members: []Member{{Key: "a", Value: 3}}, read: 200, done: true}})
+	m = updated.(model)
+	assert.Nil(t, m.loads)
+	assert.True(t, *canceled)
+	assert.Nil(t, cmd, "Not watching a file")
+	assert.Equal(t, 5, m.visibleLines2.Len())
+	assert.Contains(t, m.statusBar, "duplicate key")
+	assert.NotContains(t, m.UpdateStatusBar(), "Loading")
+}
+
+func TestApplyLoad_ParseError(t *testing.T) {
+	m, _ := newLoadingModel(10)
+	input := []byte(`{"a": 1, "b": }`)
+
+	updated, _ := m.Update(loadMsg{source: input, format: defaultFormat})
+	m = updated.(model)
+	tree, err := loadInBatches(string(input), options{})
+	updated, _ = m.Update(loadMsg{tree: tree, loadBatch: loadBatch{done: true, err: err}})
+	m = updated.(model)
+
+	// Like at startup, the error screen shows where the input is invalid
+	assert.Equal(t, ParseFailed, m.mode)
+	assert.Equal(t, 1, m.parseErr.Line)
+	assert.Equal(t, 15, m.parseErr.Column)
+}
+
+func TestApplyLoad_Stopped(t *testing.T) {
+	m, canceled := newLoadingModel(10)
+	m.stopLoading()
+	*canceled = false
+
+	// A message sent before the loader saw the cancellation is dropped,
+	// and the loader is canceled again rather than waited for
+	updated, cmd := m.Update(loadMsg{loadBatch: loadBatch{opened: true, root: Object{}, done: true}})
+	m = updated.(model)
+	assert.Nil(t, cmd)
+	assert.True(t, *canceled)
+	assert.Equal(t, 0, m.tree.Len())
+}
+
+func TestJSONReader_RecordError(t *testing.T) {
+	input := []byte(`[1] {"a": }`)
+	r := newJSONReader(input)
+
+	_, err := r.record(4, int64(len(input)), '{')
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	// The offset is in the whole input, at the "}"
+	assert.Equal(t, int64(10), parseErr.Offset)
+}
+
+func TestApplyLoad_CtrlC(t *testing.T) {
+	m, canceled := newLoadingModel(10)
+
+	// Ctrl-C stops the loader and quits
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	assert.True(t, *canceled)
+	assert.Equal(t, tea.Quit(), cmd())
+}
+
+func TestStartLoading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	input := `{"a": [1, 2], "b": {"c": "d"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(input), 0o644))
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, _ := newLoadingModel(10)
+	m.loads = startLoading(ctx, file, path, options{})
+	for m.loads != nil {
+		updated, _ := m.Update(waitForLoad(m.loads)())
+		m = updated.(model)
+	}
+
+	assert.Equal(t, mustLoadTree(t, input).PrintAsJSON2(), m.tree.PrintAsJSON2())
+	assert.Equal(t, defaultFormat, m.opts.format)
+	assert.Equal(t, []byte(input), m.source)
+	assert.Equal(t, int64(len(input)), m.loadTotal)
+}
+
+func TestStartLoading_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	loads := startLoading(ctx, strings.NewReader(`[1, 2, 3]`), "", options{})
+	cancel()
+
+	// The channel is closed without waiting for the model
+	for range loads {
+	}
+}
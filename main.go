@@ -1,16 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"slices"
 	"strconv"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
 	"github.com/mattn/go-isatty"
 )
@@ -25,36 +26,49 @@ const (
 	Visual
 	Search
 	Error
+	ParseFailed
 )
 
 func main() {
-	var args []string
-	for _, arg := range os.Args[1:] {
-		switch arg {
-		case "-h", "--help":
-			fmt.Println(usage())
-			return
-		case "-v", "-V", "--version":
-			fmt.Println("vj", version)
-			return
-		default:
-			args = append(args, arg)
-		}
+	parsed, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\nRun vj -h for the usage.\n", err)
+		os.Exit(1)
+	}
+	if parsed.help {
+		fmt.Println(usage())
+		return
 	}
+	if parsed.version {
+		fmt.Println("vj", version)
+		return
+	}
+	args, opts := parsed.files, parsed.opts
 
 	fd := os.Stdin.Fd()
 	stdinIsTty := isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
 
 	var src io.Reader
-
-	if stdinIsTty {
+	filePath := ""
+	var request *httpRequest
+	var command *shellCommand
+
+	if parsed.command != "" {
+		// $ vj --cmd 'kubectl get pods -o json'
+		// The command runs once vj has started
+		command = &shellCommand{line: parsed.command, format: opts.format}
+	} else if len(args) > 0 && isURL(args[0]) {
+		// $ vj https://host/api/items
+		request = &parsed.request
+		request.url = args[0]
+	} else if stdinIsTty {
 		if len(args) == 0 {
 			// $ vj
 			fmt.Println(usage())
 			return
 		} else {
 			// $ vj file.json
-			filePath := args[0]
+			filePath = args[0]
 			file, err := os.OpenFile(filePath, os.O_RDONLY, 0)
 			if err != nil {
 				fmt.Printf("Error reading file: %v\n", err)
@@ -68,19 +82,90 @@ func main() {
 		src = os.Stdin
 	}
 
-	jsonInputBytes, err := io.ReadAll(src)
-	if err != nil {
-		panic(err)
-	}
+	if opts.follow {
+		if request != nil || command != nil {
+			fmt.Println("Error: --follow cannot read a URL or a command")
+			os.Exit(1)
+		}
 
-	// Parse JSON
-	var data interface{}
-	if err := json.Unmarshal(jsonInputBytes, &data); err != nil {
-		panic(err)
+		// Only JSON records can be read as they arrive
+		if opts.format == "" {
+			opts.format = detectFormat(filePath, nil)
+		}
+		if opts.format != defaultFormat {
+			fmt.Println("Error: --follow only reads JSON records")
+			os.Exit(1)
+		}
 	}
 
-	// Build tree
-	tree := BuildTree(data, "", nil)
+	m := model{opts: opts}
+
+	if command != nil {
+		m.tree = NewJSONTree()
+		m.command = command
+		m.statusBar = "Running: " + command.line
+	} else if opts.follow {
+		// $ vj -f file.ndjson, or tail -f ... | vj --follow
+		// Records are added to the tree while vj is running
+		if filePath != "" {
+			src = &followReader{file: src.(*os.File), poll: followPollInterval}
+		}
+		records := make(chan recordsMsg, followBatchSize)
+		go readRecords(src, records)
+
+		m.tree = NewJSONTree()
+		m.tree.Stream = true
+		m.records = records
+		m.statusBar = waitingForData
+	} else if request == nil {
+		// A file or a pipe is read in the background while vj starts,
+		// the top-level nodes are shown as they are parsed
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		m.tree = NewJSONTree()
+		m.loads = startLoading(ctx, src, filePath, opts)
+		m.cancelLoad = cancel
+
+		// Watch the file and reload it when it changes on disk
+		if filePath != "" {
+			m.filePath = filePath
+			m.fileState, _ = statFile(filePath)
+		}
+	} else {
+		// The response of a URL, :reload makes the request again
+		input, compressed, response, err := fetch(*request)
+		if err != nil {
+			fmt.Printf("Error fetching URL: %v\n", err)
+			os.Exit(1)
+		}
+		m.request = request
+		m.response = response
+		if m.opts.format == "" {
+			m.opts.format = detectResponseFormat(*request, m.response, input)
+		}
+		m.source = input
+		m.compressed = compressed
+
+		// Parse the input and build the tree, on error keep the
+		// part that was read before it
+		tree, err := loadTree(input, m.opts)
+		if err != nil {
+			if !errors.As(err, &m.parseErr) {
+				fmt.Printf("Error parsing input: %v\n", err)
+				os.Exit(1)
+			}
+			m.parseErr.Locate(input)
+			m.mode = ParseFailed
+		}
+		if tree == nil {
+			// Nothing to browse, the model still needs a tree
+			tree = NewJSONTree()
+		}
+		m.tree = tree
+		if m.mode != ParseFailed {
+			m.statusBar = summarizeDuplicates(tree)
+		}
+	}
 	SetCurrentTheme("dark")
 
 	if len(os.Getenv("DEBUG")) > 0 {
@@ -92,8 +177,7 @@ func main() {
 		defer f.Close()
 	}
 
-	p := tea.NewProgram(
-		model{tree: tree}, tea.WithAltScreen())
+	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
@@ -117,6 +201,22 @@ type model struct {
 	searchBuffer       string
 	searchResults      []SearchMatch
 	currentMatchIndex  int
+	source             []byte
+	parseErr           *ParseError
+	records            <-chan recordsMsg
+	loads              <-chan loadMsg // the input is still read, see startLoading
+	cancelLoad         context.CancelFunc
+	loadRead           int64
+	loadTotal          int64
+	opts               options
+	filePath           string
+	fileState          fileState
+	changeGeneration   int
+	compressed         compression
+	request            *httpRequest  // the document was fetched from a URL
+	command            *shellCommand // the document is the output of a command
+	stderr             string        // the end of the stderr of the command
+	response           httpResponse
 }
 
 type SearchMatch struct {
@@ -127,6 +227,19 @@ type SearchMatch struct {
 }
 
 func (m model) Init() tea.Cmd {
+	if m.loads != nil {
+		// The file is watched once it is loaded
+		return waitForLoad(m.loads)
+	}
+	if m.records != nil {
+		return waitForRecords(m.records)
+	}
+	if m.command != nil {
+		return execCommand(*m.command, m.opts)
+	}
+	if m.filePath != "" {
+		return watchFile()
+	}
 	return nil
 }
 
@@ -134,6 +247,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		{
+			if msg.String() == "ctrl+c" && m.loads != nil {
+				// Stop reading a big input that was opened by mistake
+				m.stopLoading()
+				return m, tea.Quit
+			}
+
 			switch m.mode {
 			case Normal:
 				return m.UpdateNormalMode(msg)
@@ -146,9 +265,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			case Error:
 				return m.UpdateErrorMode(msg)
+
+			case ParseFailed:
+				return m.UpdateParseErrorMode(msg)
 			}
 		}
 
+	case recordsMsg:
+		return m.appendRecords(msg)
+
+	case loadMsg:
+		return m.applyLoad(msg)
+
+	case fileCheckMsg:
+		return m.checkFile()
+
+	case reloadMsg:
+		return m.applyReload(msg)
+
+	case commandMsg:
+		return m.applyCommand(msg)
+
+	case clearChangesMsg:
+		return m.clearChanges(msg)
+
 	case tea.WindowSizeMsg:
 		{
 			if !m.ready {
@@ -156,12 +296,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.windowLines = msg.Height - 1 // for the status bar
 				m.width = msg.Width
 
-				m.tree.PrintAsJSONFromRoot()
-
+				// Nothing to show when the input was invalid from the start
 				m.visibleLines2 = NewVisibleLines2(
-					m.firstVisibleLine, m.windowLines,
-					m.tree.PrintAsJSON2(),
-				)
+					m.firstVisibleLine, m.windowLines, m.tree)
 
 				m.ready = true
 			} else {
@@ -207,11 +344,10 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		{
 			// Move the cursos to the top
 			m.cursorY = 0
-			physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-			node, exists := m.tree.GetNodeAtLine(physicalLine)
+			node, exists := m.nodeAtCursor()
 			m.currentPath = ""
 			if exists {
-				m.currentPath = "." + node.Path
+				m.currentPath = m.tree.displayPath(node)
 			}
 			m.statusBar = m.currentPath
 			m.ScrollUp()
@@ -219,8 +355,8 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "G":
 		{
 			// Move the cursos to the end of the file
-			if len(m.tree.VirtualToRealLines) > 0 {
-				m.cursorY = len(m.tree.VirtualToRealLines) - 1
+			if m.visibleLines2.Len() > 0 {
+				m.cursorY = m.visibleLines2.Len() - 1
 				m.statusBar = ""
 				m.ScrollDown()
 			}
@@ -237,11 +373,10 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.cursorY = 0
 			}
 
-			physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-			node, exists := m.tree.GetNodeAtLine(physicalLine)
+			node, exists := m.nodeAtCursor()
 			m.currentPath = ""
 			if exists {
-				m.currentPath = "." + node.Path
+				m.currentPath = m.tree.displayPath(node)
 			}
 			m.statusBar = m.currentPath
 
@@ -255,14 +390,13 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			m.cursorY += steps
 
-			if m.cursorY >= len(m.visibleLines2.content) {
-				m.cursorY = len(m.visibleLines2.content) - 1
+			if m.cursorY >= m.visibleLines2.Len() {
+				m.cursorY = max(m.visibleLines2.Len()-1, 0)
 			}
-			physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-			node, exists := m.tree.GetNodeAtLine(physicalLine)
+			node, exists := m.nodeAtCursor()
 			m.currentPath = ""
 			if exists {
-				m.currentPath = "." + node.Path
+				m.currentPath = m.tree.displayPath(node)
 			}
 			m.statusBar = m.currentPath
 			m.ScrollDown()
@@ -270,11 +404,13 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "left", "h":
 		{
-			physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-			node, exists := m.tree.GetNodeAtLine(physicalLine)
+			node, exists := m.nodeAtCursor()
 			if exists {
-				m.tree.Collapse(node.Path)
-				m.visibleLines2.UpdateContent2(m.tree.PrintAsJSON2())
+				// A decoded string folds back into the string
+				if !m.tree.restoreString(node.ID) {
+					m.tree.setCollapsed(node.ID, true)
+				}
+				m.visibleLines2.UpdateContent2(m.tree)
 				m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
 					m.visibleLines2.total)
 			}
@@ -282,16 +418,38 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "right", "l":
 		{
-			physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-			node, exists := m.tree.GetNodeAtLine(physicalLine)
+			node, exists := m.nodeAtCursor()
 			if exists {
-				m.tree.Expand(node.Path)
-				m.visibleLines2.UpdateContent2(m.tree.PrintAsJSON2())
+				// A string that holds JSON unfolds into its subtree, the
+				// children of a big file are read when it unfolds
+				if err := m.tree.load(node.ID); err != nil {
+					m.statusBar = errorStyle.Render("Error: " + err.Error())
+				} else if !m.tree.decodeString(node.ID, []stringDecoder{jsonDecoder}) {
+					m.tree.setCollapsed(node.ID, false)
+				}
+				m.visibleLines2.UpdateContent2(m.tree)
+				m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
+					m.visibleLines2.total)
+			}
+		}
+
+	case "d":
+		{
+			// The other encodings are guessed from the string, so they
+			// are only tried when asked for
+			node, exists := m.nodeAtCursor()
+			if exists && m.tree.decodeString(node.ID, stringDecoders) {
+				m.visibleLines2.UpdateContent2(m.tree)
 				m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
 					m.visibleLines2.total)
+			} else if exists {
+				m.statusBar = "No encoding recognized"
 			}
 		}
 
+	case "r":
+		return m.reload()
+
 	case "{":
 		m.moveToPreviousSibling()
 
@@ -317,11 +475,10 @@ func (m model) UpdateNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "esc":
 		{
-			physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-			node, exists := m.tree.GetNodeAtLine(physicalLine)
+			node, exists := m.nodeAtCursor()
 			m.currentPath = ""
 			if exists {
-				m.currentPath = "." + node.Path
+				m.currentPath = m.tree.displayPath(node)
 			}
 			m.statusBar = m.currentPath
 		}
@@ -426,16 +583,80 @@ func (m model) runCommand() (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	}
 
+	// Go back to the parse error screen
+	if command == "error" && m.parseErr != nil {
+		m.mode = ParseFailed
+		m.commandBuffer = ""
+		return m, nil
+	}
+
+	// Run a shell command, its output replaces the document
+	if line, found := strings.CutPrefix(command, "run "); found && strings.TrimSpace(line) != "" {
+		m.commandBuffer = ""
+		return m.startCommand(strings.TrimSpace(line))
+	}
+
+	// Load the document again: run the command again, make the request
+	// again for a URL, or read the file again
+	if command == "reload" {
+		m.mode = Normal
+		m.commandBuffer = ""
+		return m.reload()
+	}
+
+	// Jump to the next member with a duplicate key
+	if command == "dup" {
+		m.commandBuffer = ""
+		if !m.jumpToNextDuplicate() {
+			m.mode = Error
+			m.statusBar = errorStyle.Render("Error: No duplicate keys")
+			return m, nil
+		}
+		m.mode = Normal
+		m.statusBar = m.currentPath
+		return m, nil
+	}
+
+	// Turn the value annotations on or off, or list them
+	if command == "set" || strings.HasPrefix(command, "set ") {
+		m.commandBuffer = ""
+		for _, option := range strings.Fields(strings.TrimPrefix(command, "set")) {
+			if err := setAnnotator(option); err != nil {
+				m.mode = Error
+				m.statusBar = errorStyle.Render("Error: " + err.Error())
+				return m, nil
+			}
+		}
+		m.mode = Normal
+		m.statusBar = annotatorSettings()
+		return m, nil
+	}
+
 	// Handle path navigation commands
-	if strings.HasPrefix(command, ".") {
-		path := strings.TrimPrefix(command, ".")
-		if node, exists := m.tree.Nodes[path]; exists {
+	if strings.HasPrefix(command, ".") || strings.HasPrefix(command, "/") {
+		id, err := m.tree.Resolve(command)
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			// A node on the way cannot be read from the file
+			m.mode = Error
+			m.statusBar = errorStyle.Render("Error: " + err.Error())
+			m.commandBuffer = ""
+			return m, nil
+		}
+		if err != nil && !errors.Is(err, errPathNotFound) {
+			// The path cannot be read, tell why
+			m.mode = Error
+			m.statusBar = errorStyle.Render("Error: Invalid path: " + err.Error())
+			m.commandBuffer = ""
+			return m, nil
+		}
+		if err == nil {
 			// Find the virtual line that corresponds to this path
-			virtualLine, found := m.findVirtualLineForPath(path)
+			virtualLine, found := m.findVirtualLine(id)
 
 			if found {
 				m.cursorY = virtualLine
-				m.currentPath = "." + node.Path
+				m.currentPath = m.tree.displayPath(&m.tree.nodes[id])
 
 				// Ensure the cursor is visible (scroll if needed)
 				m.ScrollDown()
@@ -450,14 +671,14 @@ func (m model) runCommand() (tea.Model, tea.Cmd) {
 			} else {
 				// Path exists but is not currently visible (might me collapsed)
 				m.mode = Error
-				m.statusBar = errorStyle.Render("Error: Path not visible (may be collapsed): ." + path)
+				m.statusBar = errorStyle.Render("Error: Path not visible (may be collapsed): " + command)
 				m.commandBuffer = ""
 				return m, nil
 			}
 		} else {
 			// Path does not exist
 			m.mode = Error
-			m.statusBar = errorStyle.Render("Error: Path not found: ." + path)
+			m.statusBar = errorStyle.Render("Error: Path not found: " + command)
 			m.commandBuffer = ""
 			return m, nil
 		}
@@ -471,47 +692,39 @@ func (m model) runCommand() (tea.Model, tea.Cmd) {
 }
 
 func (m *model) findVirtualLineForPath(path string) (int, bool) {
-	node, exists := m.tree.Nodes[path]
-	if !exists {
+	id := m.tree.lookup(path)
+	if id == noNode {
 		return 0, false
 	}
-
-	// Find virtual line that corresponds to this real line
-	for virtualLine, realLine := range m.tree.VirtualToRealLines {
-		if realLine == node.LineNumber {
-			return virtualLine, true
-		}
-	}
-	return 0, false
+	return m.findVirtualLine(id)
 }
 
-func (m *model) isPathVisible(path string) bool {
-	node, exists := m.tree.Nodes[path]
-	if !exists {
-		return false
-	}
-
-	if slices.Contains(m.tree.VirtualToRealLines, node.LineNumber) {
-		return true
-	}
+// findVirtualLine returns the virtual line of the node, when it is shown
+func (m *model) findVirtualLine(id NodeID) (int, bool) {
+	// The root of a stream and the removed nodes have no line
+	return m.tree.lines.virtual(m.tree.nodes[id].line)
+}
 
-	return false
+// isVisible reports whether the node has a line on screen, its parents
+// are not folded
+func (m *model) isVisible(id NodeID) bool {
+	_, visible := m.findVirtualLine(id)
+	return visible
 }
 
 // Get visble siblings only
-func (m *model) getVisibleSiblings() []string {
-	physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-	currentNode, exists := m.tree.GetNodeAtLine(physicalLine)
-	if !exists {
+func (m *model) getVisibleSiblings() []NodeID {
+	currentNode, exists := m.nodeAtCursor()
+	if !exists || m.tree.Parent(currentNode.ID) == noNode {
 		return nil
 	}
 
-	allSiblings := m.tree.GetChildren(currentNode.Parent)
-	visibleSiblings := make([]string, 0)
+	allSiblings := m.tree.children(m.tree.Parent(currentNode.ID))
+	visibleSiblings := make([]NodeID, 0)
 
-	for _, siblingPath := range allSiblings {
-		if m.isPathVisible(siblingPath) {
-			visibleSiblings = append(visibleSiblings, siblingPath)
+	for _, sibling := range allSiblings {
+		if m.isVisible(sibling) {
+			visibleSiblings = append(visibleSiblings, sibling)
 		}
 	}
 
@@ -524,13 +737,12 @@ func (m *model) moveToNextSibling() {
 		return // No siblings or only current node
 	}
 
-	physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-	currentNode, _ := m.tree.GetNodeAtLine(physicalLine)
+	currentNode, _ := m.nodeAtCursor()
 	currentIndex := -1
 
 	// Find current position in siblings array
-	for i, siblingPath := range siblings {
-		if siblingPath == currentNode.Path {
+	for i, sibling := range siblings {
+		if sibling == currentNode.ID {
 			currentIndex = i
 			break
 		}
@@ -541,8 +753,7 @@ func (m *model) moveToNextSibling() {
 	}
 
 	// Move to next sibling
-	nextSiblingPath := siblings[currentIndex+1]
-	if virtualLine, found := m.findVirtualLineForPath(nextSiblingPath); found {
+	if virtualLine, found := m.findVirtualLine(siblings[currentIndex+1]); found {
 		m.cursorY = virtualLine
 		m.updateCurrentPath()
 		m.ScrollDown()
@@ -555,12 +766,11 @@ func (m *model) moveToPreviousSibling() {
 		return
 	}
 
-	physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-	currentNode, _ := m.tree.GetNodeAtLine(physicalLine)
+	currentNode, _ := m.nodeAtCursor()
 	currentIndex := -1
 
-	for i, siblingPath := range siblings {
-		if siblingPath == currentNode.Path {
+	for i, sibling := range siblings {
+		if sibling == currentNode.ID {
 			currentIndex = i
 			break
 		}
@@ -570,20 +780,28 @@ func (m *model) moveToPreviousSibling() {
 		return // Not found or already at first sibling
 	}
 
-	prevSiblingPath := siblings[currentIndex-1]
-	if virtualLine, found := m.findVirtualLineForPath(prevSiblingPath); found {
+	if virtualLine, found := m.findVirtualLine(siblings[currentIndex-1]); found {
 		m.cursorY = virtualLine
 		m.updateCurrentPath()
 		m.ScrollUp()
 	}
 }
 
+// nodeAtCursor returns the node on the cursor line. Closing brackets
+// have no node, and the tree has no lines while follow mode waits for
+// the first record.
+func (m *model) nodeAtCursor() (*Node, bool) {
+	if m.cursorY < 0 || m.cursorY >= m.visibleLines2.Len() {
+		return nil, false
+	}
+	return m.tree.GetNodeAtLine(m.tree.RealLine(m.cursorY))
+}
+
 // Helper to update current path
 func (m *model) updateCurrentPath() {
-	physicalLine := m.tree.VirtualToRealLines[m.cursorY]
-	node, exists := m.tree.GetNodeAtLine(physicalLine)
+	node, exists := m.nodeAtCursor()
 	if exists {
-		m.currentPath = node.Path
+		m.currentPath = m.tree.displayPath(node)
 		if m.mode == Normal {
 			m.statusBar = m.currentPath
 		}
@@ -594,11 +812,16 @@ func (m model) View() string {
 	if !m.ready {
 		return "loading"
 	}
+
+	if m.mode == ParseFailed {
+		return m.RenderParseError()
+	}
+
 	s := m.Render()
 
 	// Print ~ on blank lines
 	blankLines := m.windowLines -
-		len(m.visibleLines2.content) +
+		m.visibleLines2.Len() +
 		m.visibleLines2.firstLine
 
 	for range blankLines {
@@ -611,6 +834,21 @@ func (m model) View() string {
 
 func (m model) UpdateStatusBar() string {
 	s := m.statusBar
+
+	// The input format goes on the right when there is room
+	info := m.opts.format
+	if m.request != nil {
+		info = m.response.String() + " · " + info
+	}
+	if m.compressed.format != "" {
+		info += " · " + m.compressed.String()
+	}
+	if m.loads != nil {
+		info = m.loadProgress()
+	}
+	if gap := m.width - lipgloss.Width(s) - lipgloss.Width(info) - 1; info != "" && gap > 0 {
+		s += strings.Repeat(" ", gap) + noteStyle.Render(info)
+	}
 	return s
 }
 
@@ -620,11 +858,12 @@ func (m model) Render() string {
 	for i, line := range m.visibleLines2.linesOnScreen {
 		// Print line at cursor
 		if i+m.visibleLines2.firstLine == m.cursorY {
-			num := m.tree.VirtualToRealLines[m.cursorY] + 1
+			num := m.tree.RealLine(m.cursorY) + 1
 
 			s += fmt.Sprintf(
-				"%s %s \n",
+				"%s%s%s \n",
 				lineNumbersCol.Render(strconv.Itoa(num)+" "),
+				RenderChange(line.Change),
 				RenderLine(line, true),
 			)
 		}
@@ -633,8 +872,9 @@ func (m model) Render() string {
 		if i+m.visibleLines2.firstLine < m.cursorY {
 			num := (m.cursorY - m.visibleLines2.firstLine) - i
 			s += fmt.Sprintf(
-				"%s %s \n",
+				"%s%s%s \n",
 				lineNumbersCol.Render(strconv.Itoa(num)),
+				RenderChange(line.Change),
 				RenderLine(line, false),
 			)
 		}
@@ -643,8 +883,9 @@ func (m model) Render() string {
 		if i+m.visibleLines2.firstLine > m.cursorY {
 			num := i - (m.cursorY - m.visibleLines2.firstLine)
 			s += fmt.Sprintf(
-				"%s %s \n",
+				"%s%s%s \n",
 				lineNumbersCol.Render(strconv.Itoa(num)),
+				RenderChange(line.Change),
 				RenderLine(line, false),
 			)
 		}
@@ -654,6 +895,20 @@ func (m model) Render() string {
 }
 
 func RenderLine(line LineMetadata, hasCursor bool) string {
+	s := renderJSONLine(line, hasCursor)
+	if text := annotationText(line); text != "" {
+		s += " " + text
+	}
+	if line.Duplicate {
+		s += " " + warningStyle.Render("⚠ duplicate key")
+	}
+	if line.Note != "" {
+		s += " " + noteStyle.Render(line.Note)
+	}
+	return s
+}
+
+func renderJSONLine(line LineMetadata, hasCursor bool) string {
 	isSelected := false
 	indent := strings.Repeat("  ", line.Indent)
 
@@ -748,10 +1003,18 @@ func RenderLine(line LineMetadata, hasCursor bool) string {
 						line.Content+`"`,
 						hasCursor, isSelected) +
 					RenderSyntax(comma, false, isSelected)
-			case NumberType:
+			case IntegerType, FloatType:
 				return RenderIndent(indent, isSelected) +
 					RenderNumber(line.Content, hasCursor, isSelected) +
 					RenderSyntax(comma, false, isSelected)
+			case DateTimeType:
+				return RenderIndent(indent, isSelected) +
+					RenderDateTime(line.Content, hasCursor, isSelected) +
+					RenderSyntax(comma, false, isSelected)
+			case BytesType:
+				return RenderIndent(indent, isSelected) +
+					RenderBytes(line.Content, hasCursor, isSelected) +
+					RenderSyntax(comma, false, isSelected)
 			case BoolType:
 				return RenderIndent(indent, isSelected) +
 					RenderBoolean(line.Content, hasCursor, isSelected) +
@@ -769,8 +1032,12 @@ func RenderLine(line LineMetadata, hasCursor bool) string {
 			switch line.NodeType {
 			case StringType:
 				valuePart = stringStyle.Render(`"` + line.Content + `"`)
-			case NumberType:
+			case IntegerType, FloatType:
 				valuePart = numberStyle.Render(line.Content)
+			case DateTimeType:
+				valuePart = dateTimeStyle.Render(line.Content)
+			case BytesType:
+				valuePart = bytesStyle.Render(line.Content)
 			case BoolType:
 				valuePart = booleanStyle.Render(line.Content)
 			case NullType:
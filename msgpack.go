@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// MessagePack extension type of timestamps
+const msgpackTimestamp = -1
+
+// ParseMsgpack decodes a sequence of MessagePack objects. Binary data is
+// Bytes and timestamps DateTime values; other extension types are Bytes
+// with the type in their note.
+//
+// On invalid input it returns a *ParseError and the objects decoded
+// before the error.
+func ParseMsgpack(src []byte) ([]interface{}, error) {
+	d := msgpackDecoder{byteReader{src: src}}
+
+	var items []interface{}
+	for d.pos < len(src) {
+		item, err := d.item()
+		if err != nil {
+			return items, &ParseError{Err: err, Offset: int64(d.pos)}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+type msgpackDecoder struct {
+	byteReader
+}
+
+// item decodes the next object
+func (d *msgpackDecoder) item() (interface{}, error) {
+	head, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	b := head[0]
+
+	switch {
+	case b <= 0x7f:
+		return json.Number(strconv.Itoa(int(b))), nil
+	case b >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(b)))), nil
+	case b <= 0x8f:
+		return d.object(uint64(b & 0x0f))
+	case b <= 0x9f:
+		return d.array(uint64(b & 0x0f))
+	case b <= 0xbf:
+		return d.str(uint64(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.read(n)
+		return Bytes(append([]byte{}, data...)), err
+
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+
+	case 0xca:
+		bits, err := d.readUint(4)
+		return floatLiteral(float64(math.Float32frombits(uint32(bits))), 32), err
+	case 0xcb:
+		bits, err := d.readUint(8)
+		return floatLiteral(math.Float64frombits(bits), 64), err
+
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.readUint(1 << (b - 0xcc))
+		return json.Number(strconv.FormatUint(n, 10)), err
+
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := d.readUint(size)
+		// Sign-extend the value from its size
+		shift := 64 - 8*size
+		return json.Number(strconv.FormatInt(int64(n<<shift)>>shift, 10)), err
+
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (b - 0xd4))
+
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n)
+
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(n)
+	}
+
+	return nil, fmt.Errorf("invalid MessagePack byte 0x%02x", b)
+}
+
+func (d *msgpackDecoder) str(n uint64) (interface{}, error) {
+	s, err := d.read(n)
+	return string(s), err
+}
+
+func (d *msgpackDecoder) array(size uint64) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	n, err := d.count(size)
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		item, err := d.item()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, item)
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) object(size uint64) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	n, err := d.count(size)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := make(Object, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := d.item()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.item()
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, Member{Key: mapKey(key), Value: value})
+	}
+	return obj, nil
+}
+
+// ext decodes an extension object with n bytes of data
+func (d *msgpackDecoder) ext(n uint64) (interface{}, error) {
+	typ, err := d.readUint(1)
+	if err != nil {
+		return nil, err
+	}
+	extType := int8(typ)
+
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if extType == msgpackTimestamp {
+		if t, ok := msgpackTime(data); ok {
+			return Annotated{
+				Value: DateTime(t.Format(time.RFC3339Nano)),
+				Note:  "ext -1 (timestamp)",
+			}, nil
+		}
+	}
+
+	return Annotated{
+		Value: Bytes(append([]byte{}, data...)),
+		Note:  fmt.Sprintf("ext %d", extType),
+	}, nil
+}
+
+// msgpackTime decodes the data of the timestamp extension type, in one of
+// its 32, 64 and 96 bit formats
+func msgpackTime(data []byte) (time.Time, bool) {
+	var sec int64
+	var nsec int64
+
+	switch len(data) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		n := binary.BigEndian.Uint64(data)
+		nsec = int64(n >> 34)
+		sec = int64(n & (1<<34 - 1))
+	case 12:
+		nsec = int64(binary.BigEndian.Uint32(data))
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return time.Time{}, false
+	}
+
+	return time.Unix(sec, nsec).UTC(), true
+}
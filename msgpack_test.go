@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMsgpack(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7f", json.Number("127")},
+		{"e0", json.Number("-32")},
+		{"d0 9c", json.Number("-100")},
+		{"d3 8000000000000000", json.Number("-9223372036854775808")},
+		{"cf ffffffffffffffff", json.Number("18446744073709551615")},
+		{"ca 3f800000", json.Number("1.0")},
+		{"cb 3ff199999999999a", json.Number("1.1")},
+		{"c0", nil},
+		{"c3", true},
+		{"a3 616263", "abc"},
+		{"d9 03 616263", "abc"},
+		{"c4 03 010203", Bytes{1, 2, 3}},
+		{"82 a1 61 01 a1 62 92 02 03", Object{
+			{Key: "a", Value: json.Number("1")},
+			{Key: "b", Value: []interface{}{json.Number("2"), json.Number("3")}},
+		}},
+		{"de 0001 01 c2", Object{{Key: "1", Value: false}}},
+		{"dc 0001 90", []interface{}{[]interface{}{}}},
+		// Extension types
+		{"d6 ff 00000000", Annotated{Value: DateTime("1970-01-01T00:00:00Z"),
+			Note: "ext -1 (timestamp)"}},
+		{"c7 0c ff 00000001 0000000000000002", Annotated{
+			Value: DateTime("1970-01-01T00:00:02.000000001Z"), Note: "ext -1 (timestamp)"}},
+		{"d4 05 aa", Annotated{Value: Bytes{0xaa}, Note: "ext 5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			items, err := ParseMsgpack(mustDecodeHex(t, tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{tt.expected}, items)
+		})
+	}
+}
+
+func TestParseMsgpack_TooDeep(t *testing.T) {
+	input := bytes.Repeat([]byte{0x91}, maxNestingDepth+1)
+
+	_, err := ParseMsgpack(append(input, 0x01))
+	assert.ErrorContains(t, err, "items nested more than 10000 levels deep")
+
+	items, err := ParseMsgpack(append(input[1:], 0x01))
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func TestParseMsgpack_Error(t *testing.T) {
+	_, err := ParseMsgpack(mustDecodeHex(t, "91 c1"))
+	assert.EqualError(t, err, "byte 2: invalid MessagePack byte 0xc1")
+}
+
+func TestSniffBinaryFormat(t *testing.T) {
+	assert.Equal(t, "msgpack", sniffBinaryFormat(mustDecodeHex(t, "81 a1 61 c4 01 ff")))
+	assert.Equal(t, "cbor", sniffBinaryFormat(mustDecodeHex(t, "a1 61 61 41 ff")))
+	assert.Equal(t, "cbor", sniffBinaryFormat(mustDecodeHex(t, "d9d9f7 01")))
+	assert.Equal(t, "", sniffBinaryFormat([]byte(`{"a": 1}`)))
+
+	// Only the start of a big input is decoded
+	big := append(mustDecodeHex(t, "dc ff ff"), bytes.Repeat([]byte{0x01}, 0xffff)...)
+	assert.Equal(t, "msgpack", sniffBinaryFormat(big))
+	assert.Equal(t, "", sniffBinaryFormat(big[:sniffSize]))
+
+	// The tree shows the bytes in its own node type
+	tree, err := loadTree(mustDecodeHex(t, "81 a1 61 c4 01 ff"), options{})
+	assert.NoError(t, err)
+	assert.Equal(t, BytesType, tree.Node("a").Type)
+}
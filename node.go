@@ -1,35 +1,95 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"strings"
 )
 
 type NodeType string
 
 const (
-	StringType NodeType = "string"
-	NumberType NodeType = "number"
-	BoolType   NodeType = "boolean"
-	ObjectType NodeType = "object"
-	ArrayType  NodeType = "array"
-	NullType   NodeType = "null"
+	StringType   NodeType = "string"
+	IntegerType  NodeType = "integer"
+	FloatType    NodeType = "float"
+	BoolType     NodeType = "boolean"
+	ObjectType   NodeType = "object"
+	ArrayType    NodeType = "array"
+	NullType     NodeType = "null"
+	DateTimeType NodeType = "datetime"
+	BytesType    NodeType = "bytes"
 )
 
+// Object is a JSON object that keeps its members in document order.
+type Object []Member
+
+// Member is a single key/value pair of an Object
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Annotated attaches a note, such as a comment from the source, to a
+// decoded value. BuildTree unwraps it and keeps the note on the node.
+type Annotated struct {
+	Value interface{}
+	Note  string
+}
+
+// DateTime is a date, a time or both, such as a TOML datetime. It is
+// kept as written, for example 1979-05-27T07:32:00Z or 07:32:00.
+type DateTime string
+
+// Bytes is a byte string from a binary format like CBOR or MessagePack
+type Bytes []byte
+
+// Byte strings up to this size are shown in hex, longer ones in base64
+const hexPreviewSize = 16
+
+// Number of base64 characters shown for long byte strings
+const base64PreviewLength = 44
+
+// String returns a preview of the bytes in the CBOR diagnostic notation:
+// h'48656c6c6f' for short byte strings, b64'...' followed by the size for
+// longer ones.
+func (b Bytes) String() string {
+	if len(b) <= hexPreviewSize {
+		return "h'" + hex.EncodeToString(b) + "'"
+	}
+
+	preview := base64.StdEncoding.EncodeToString(b)
+	if len(preview) > base64PreviewLength {
+		preview = preview[:base64PreviewLength] + "…"
+	}
+	return fmt.Sprintf("b64'%s' (%d bytes)", preview, len(b))
+}
+
+// Stream is a sequence of top-level JSON values, for example the
+// records of a newline delimited JSON (NDJSON) file
+type Stream []interface{}
+
+// Node is a value of the document. Its path is not stored, the tree
+// builds it from the keys of the node and its parents.
 type Node struct {
-	Path              string      `json:"path"`
-	Type              NodeType    `json:"type"`
-	Value             interface{} `json:"value"`
-	Parent            string      `json:"parent"`
-	Depth             int         `json:"depth"`
-	Key               string      `json:"key"`
-	IsArrayElement    bool        `json:"isArrayElement"`
-	LineNumber        int
-	ClosingLineNumber int
+	ID             NodeID      `json:"id"`
+	Type           NodeType    `json:"type"`
+	Value          interface{} `json:"value"` // nil for objects and arrays, their children hold the values
+	Depth          int         `json:"depth"`
+	Key            string      `json:"key"`
+	IsArrayElement bool        `json:"isArrayElement"`
+	Note           string      `json:"note"`
+	Encoded        string      `json:"encoded,omitempty"`    // original text of a decoded string
+	Duplicate      bool        `json:"duplicate,omitempty"`  // its key appears more than once in the object
+	Occurrence     int         `json:"occurrence,omitempty"` // n of the key#n path of an earlier occurrence of a duplicate key
+	line           int32       // in JSONTree.lines, see LineNumber
+	closingLine    int32       // of the "}" or "]" of an object or an array
 }
 
 // Helper functions
 func getNodeType(value interface{}) NodeType {
+	value, _ = unwrapValue(value)
 	if value == nil {
 		return NullType
 	}
@@ -37,44 +97,97 @@ func getNodeType(value interface{}) NodeType {
 	switch value.(type) {
 	case string:
 		return StringType
-	case float64, int, int64:
-		return NumberType
+	case json.Number:
+		return numberType(string(value.(json.Number)))
+	case int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return IntegerType
+	case float32, float64:
+		return FloatType
 	case bool:
 		return BoolType
-	case map[string]interface{}:
+	case DateTime:
+		return DateTimeType
+	case Bytes:
+		return BytesType
+	case Object, map[string]interface{}:
 		return ObjectType
 	case []interface{}:
 		return ArrayType
+	case pending:
+		if value.(pending).array {
+			return ArrayType
+		}
+		return ObjectType
 	default:
 		return StringType
 	}
 }
 
-func getDepth(path string) int {
-	if path == "" {
-		return 0
+// numberType tells integer literals apart from literals with a
+// fraction or an exponent, without converting the number. Infinity
+// and NaN from JSON5 documents are floats too.
+func numberType(literal string) NodeType {
+	if strings.ContainsAny(literal, ".eEIN") {
+		return FloatType
 	}
-	re := regexp.MustCompile(`[.\[]`)
-	matches := re.FindAllString(path, -1)
-	return len(matches)
+	return IntegerType
 }
 
+// IsNumber reports whether the node type is one of the number kinds
+func (t NodeType) IsNumber() bool {
+	return t == IntegerType || t == FloatType
+}
+
+// getDepth returns the number of segments of the path after the first
+func getDepth(path string) int {
+	segments, _ := parsePath(path)
+	return max(len(segments)-1, 0)
+}
+
+// buildChildPath returns the path of a child: base.key for keys that are
+// simple names, base["key"] for the others, and base[i] for array
+// elements. The elements of a top-level array and the records of a
+// stream are just i.
 func buildChildPath(basePath, key string,
 	isArray bool) string {
+	if isArray {
+		if basePath == "" {
+			return key
+		}
+		return fmt.Sprintf("%s[%s]", basePath, key)
+	}
+
+	if !isSimpleKey(key) {
+		return basePath + quoteKey(key)
+	}
 	if basePath == "" {
 		return key
 	}
+	return fmt.Sprintf("%s.%s", basePath, key)
+}
 
-	if isArray {
-		return fmt.Sprintf("%s[%s]", basePath, key)
+// unwrapValue returns the value and the note of an Annotated value
+func unwrapValue(value interface{}) (interface{}, string) {
+	if annotated, ok := value.(Annotated); ok {
+		return annotated.Value, annotated.Note
 	}
+	return value, ""
+}
 
-	return fmt.Sprintf("%s.%s", basePath, key)
+// leafValue returns the value kept in a node: the value itself, or nil for
+// objects and arrays
+func leafValue(value interface{}) interface{} {
+	if _, ok := value.(pending); ok || isNested(value) {
+		return nil
+	}
+	return value
 }
 
 func isNested(value interface{}) bool {
+	value, _ = unwrapValue(value)
 	switch value.(type) {
-	case map[string]interface{}, []interface{}:
+	case Object, map[string]interface{}, []interface{}:
 		return true
 	default:
 		return false
@@ -1,8 +1,11 @@
 package main
 
 import (
-	"github.com/stretchr/testify/assert"
+	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetNodeType(t *testing.T) {
@@ -19,10 +22,22 @@ func TestGetNodeType(t *testing.T) {
 			StringType,
 		},
 		{
-			"number",
+			"float",
 			map[string]interface{}{"age": 25.0},
 			"age",
-			NumberType,
+			FloatType,
+		},
+		{
+			"integer literal",
+			map[string]interface{}{"id": json.Number("12345678901234567890")},
+			"id",
+			IntegerType,
+		},
+		{
+			"float literal",
+			map[string]interface{}{"price": json.Number("0.1000000000000000055")},
+			"price",
+			FloatType,
 		},
 		{
 			"bool",
@@ -30,6 +45,18 @@ func TestGetNodeType(t *testing.T) {
 			"active",
 			BoolType,
 		},
+		{
+			"datetime",
+			map[string]interface{}{"at": DateTime("1979-05-27")},
+			"at",
+			DateTimeType,
+		},
+		{
+			"bytes",
+			map[string]interface{}{"blob": Bytes{0xff}},
+			"blob",
+			BytesType,
+		},
 		{
 			"object",
 			map[string]interface{}{"value": nil},
@@ -50,11 +77,20 @@ func TestGetNodeType(t *testing.T) {
 			node, exists := tree.GetNode(tt.path)
 
 			assert.True(t, exists, "Node should exist")
-			assert.Equal(t, tt.expected, getNodeType(node.Value))
+			assert.Equal(t, tt.expected, getNodeType(mustGetValue(t, tree, tt.path)))
+			assert.Equal(t, tt.expected, node.Type)
 		})
 	}
 }
 
+func TestBytesString(t *testing.T) {
+	assert.Equal(t, "h'48656c6c6f'", Bytes("Hello").String())
+
+	long := Bytes(strings.Repeat("a", 100))
+	assert.Equal(t, "b64'YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFh…' (100 bytes)",
+		long.String())
+}
+
 func TestGetDepth(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -89,6 +125,8 @@ func TestBuildChildPath(t *testing.T) {
 			false, "user.address.street_name"},
 		{"test array", "user.addresses[0]", "street_name",
 			true, "user.addresses[0][street_name]"},
+		{"test quoted key", "user", "a.b", false, `user["a.b"]`},
+		{"test quoted root key", "", "first name", false, `["first name"]`},
 	}
 
 	for _, tt := range tests {
@@ -116,6 +154,11 @@ func TestIsNested(t *testing.T) {
 			[]interface{}{1, 2, 3, 4},
 			true,
 		},
+		{
+			"nested ordered object",
+			Object{{Key: "name", Value: "John"}},
+			true,
+		},
 		{
 			"not nested string",
 			"some value",
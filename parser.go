@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ParseError describes where the input stopped being valid. Offset is
+// the byte offset of the offending token, Line and Column are 1-based and
+// are filled in by Locate once the source is known.
+type ParseError struct {
+	Err    error
+	Offset int64
+	Line   int
+	Column int
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d, column %d (byte %d): %v",
+			e.Line, e.Column, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("byte %d: %v", e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Locate computes the line and column of the error in src
+func (e *ParseError) Locate(src []byte) {
+	offset := min(int(e.Offset), len(src))
+	before := src[:offset]
+
+	e.Line = bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	e.Column = utf8.RuneCount(before[lineStart:]) + 1
+}
+
+func newParseError(dec *json.Decoder, err error) *ParseError {
+	offset := dec.InputOffset()
+
+	// The offset of a syntax error points right after the bad character,
+	// unless the input ended early, then there is no bad character
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+		if syntaxErr.Error() != "unexpected end of JSON input" {
+			offset = max(offset-1, 0)
+		}
+	}
+
+	return &ParseError{Err: err, Offset: offset}
+}
+
+// ParseJSON reads a single JSON document from r. It walks the input with
+// json.Decoder.Token instead of unmarshalling into a map, so objects are
+// returned as Object values that keep their members in document order.
+// Numbers are returned as json.Number to keep the original literal.
+//
+// On invalid input it returns a *ParseError together with the part of the
+// document that was read before the error.
+func ParseJSON(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	value, err := parseValue(dec)
+	if errors.Is(err, io.EOF) {
+		// The input ended before the document was complete
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return value, newParseError(dec, err)
+	}
+
+	// Like json.Unmarshal, reject anything after the top-level value.
+	// More skips the whitespace, so the offset is at the extra data.
+	if dec.More() {
+		return value, newParseError(dec,
+			errors.New("invalid data after top-level value"))
+	}
+
+	return value, nil
+}
+
+// ParseJSONStream reads a sequence of JSON values from r until the end of
+// the input, such as newline delimited JSON (NDJSON) or documents that are
+// simply concatenated. On error it returns the values read so far.
+func ParseJSONStream(r io.Reader) ([]interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var records []interface{}
+	for dec.More() {
+		value, err := parseValue(dec)
+		if err != nil {
+			if value != nil {
+				records = append(records, value)
+			}
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return records, newParseError(dec, err)
+		}
+		records = append(records, value)
+	}
+
+	// More also stops at a stray "]" or "}", so make sure
+	// the input really ended
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("invalid data after top-level value")
+		}
+		return records, newParseError(dec, err)
+	}
+
+	return records, nil
+}
+
+func parseValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return parseObject(dec)
+		case '[':
+			return parseArray(dec)
+		}
+		return nil, fmt.Errorf("unexpected delimiter %q", rune(t))
+	default:
+		return t, nil
+	}
+}
+
+func parseObject(dec *json.Decoder) (Object, error) {
+	obj := Object{}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return obj, err
+		}
+
+		key, ok := tok.(string)
+		if !ok {
+			return obj, fmt.Errorf("unexpected object key %v", tok)
+		}
+
+		value, err := parseValue(dec)
+		if err != nil {
+			// Keep the partially read value, if there is one
+			if value != nil {
+				obj = append(obj, Member{Key: key, Value: value})
+			}
+			return obj, err
+		}
+		obj = append(obj, Member{Key: key, Value: value})
+	}
+
+	// Consume the closing "}"
+	if _, err := dec.Token(); err != nil {
+		return obj, err
+	}
+
+	return obj, nil
+}
+
+func parseArray(dec *json.Decoder) ([]interface{}, error) {
+	arr := []interface{}{}
+
+	for dec.More() {
+		value, err := parseValue(dec)
+		if err != nil {
+			if value != nil {
+				arr = append(arr, value)
+			}
+			return arr, err
+		}
+		arr = append(arr, value)
+	}
+
+	// Consume the closing "]"
+	if _, err := dec.Token(); err != nil {
+		return arr, err
+	}
+
+	return arr, nil
+}
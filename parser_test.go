@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSON_KeyOrder(t *testing.T) {
+	input := `{"zebra": 1, "apple": {"y": true, "x": null}, "mango": [3, "b"]}`
+
+	data, err := ParseJSON(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	obj, ok := data.(Object)
+	assert.True(t, ok, "Root should be an Object")
+
+	var keys []string
+	for _, member := range obj {
+		keys = append(keys, member.Key)
+	}
+	assert.Equal(t, []string{"zebra", "apple", "mango"}, keys)
+
+	nested := obj[1].Value.(Object)
+	assert.Equal(t, "y", nested[0].Key)
+	assert.Equal(t, "x", nested[1].Key)
+	assert.Equal(t, []interface{}{json.Number("3"), "b"}, obj[2].Value)
+}
+
+func TestParseJSON_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"trailing comma", `{"a": 1,}`},
+		{"missing colon", `{"a" 1}`},
+		{"trailing data", `{"a": 1} {"b": 2}`},
+		{"empty input", ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJSON(strings.NewReader(tt.input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseJSON_UnexpectedEOF(t *testing.T) {
+	data, err := ParseJSON(strings.NewReader(`{"a": [1, 2`))
+	assert.Error(t, err)
+
+	// The part read before the error is kept
+	obj := data.(Object)
+	assert.Equal(t, "a", obj[0].Key)
+	assert.Equal(t, []interface{}{json.Number("1"), json.Number("2")},
+		obj[0].Value)
+}
+
+func TestBuildTree_DocumentOrder(t *testing.T) {
+	input := `{"zebra": 1, "apple": {"y": true, "x": null}, "mango": [3, "b"]}`
+
+	// Build the tree several times, the order and line
+	// numbers must be the same every time
+	for range 5 {
+		data, err := ParseJSON(strings.NewReader(input))
+		assert.NoError(t, err)
+		tree := BuildTree(data, "", nil)
+
+		assert.Equal(t, []string{"zebra", "apple", "mango"},
+			tree.GetChildren(""))
+		assert.Equal(t, []string{"apple.y", "apple.x"},
+			tree.GetChildren("apple"))
+
+		assert.Equal(t, 1, tree.LineNumber(tree.Node("zebra").ID))
+		assert.Equal(t, 2, tree.LineNumber(tree.Node("apple").ID))
+		assert.Equal(t, 4, tree.LineNumber(tree.Node("apple.x").ID))
+		assert.Equal(t, 6, tree.LineNumber(tree.Node("mango").ID))
+	}
+}
+
+func TestParseJSON_LosslessNumbers(t *testing.T) {
+	input := `{"id": 1234567890123456789, "price": 0.1000000000000000055, "exp": 1e400}`
+
+	data, err := ParseJSON(strings.NewReader(input))
+	assert.NoError(t, err)
+	tree := BuildTree(data, "", nil)
+
+	tests := []struct {
+		path     string
+		expected string
+		nodeType NodeType
+	}{
+		{"id", "1234567890123456789", IntegerType},
+		{"price", "0.1000000000000000055", FloatType},
+		{"exp", "1e400", FloatType},
+	}
+
+	lines := tree.PrintAsJSON2()
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			node := tree.Node(tt.path)
+			assert.Equal(t, tt.nodeType, node.Type)
+			assert.Equal(t, tt.expected, nodeValueToString(node))
+
+			// The rendered line must show the original literal
+			found := false
+			for _, line := range lines {
+				if tree.Path(line.NodeID) == tt.path {
+					assert.Equal(t, tt.expected, line.Content)
+					found = true
+				}
+			}
+			assert.True(t, found, "Line should be rendered")
+		})
+	}
+}
+
+func TestParseJSON_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column int
+		offset int64
+	}{
+		{"trailing comma", "{\n  \"a\": 1,\n}", 2, 9, 10},
+		{"bad literal", "{\"a\": tru}", 1, 10, 9},
+		{"trailing data", "{\"a\": 1}\n\n  {}", 3, 3, 12},
+		{"unexpected end", "[1, 2", 1, 6, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJSON(strings.NewReader(tt.input))
+
+			var parseErr *ParseError
+			assert.ErrorAs(t, err, &parseErr)
+
+			parseErr.Locate([]byte(tt.input))
+			assert.Equal(t, tt.line, parseErr.Line)
+			assert.Equal(t, tt.column, parseErr.Column)
+			assert.Equal(t, tt.offset, parseErr.Offset)
+		})
+	}
+}
+
+func TestParseError_PartialTree(t *testing.T) {
+	data, err := ParseJSON(strings.NewReader(`{"a": 1, "b": {"c": true,}}`))
+	assert.Error(t, err)
+
+	// The valid prefix can still be browsed
+	tree := BuildTree(data, "", nil)
+	assert.Equal(t, json.Number("1"), mustGetValue(t, tree, "a"))
+	assert.Equal(t, true, mustGetValue(t, tree, "b.c"))
+}
+
+func TestParseJSONStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{"ndjson", "{\"a\": 1}\n{\"a\": 2}\n{\"a\": 3}\n", 3},
+		{"concatenated", `{"a": 1}{"a": 2}[3] "four"`, 4},
+		{"single document", `{"a": [1, 2]}`, 1},
+		{"empty input", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := ParseJSONStream(strings.NewReader(tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, len(records))
+		})
+	}
+}
+
+func TestParseJSONStream_Errors(t *testing.T) {
+	records, err := ParseJSONStream(strings.NewReader("{\"a\": 1}\n{\"a\": 2}}\n"))
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, len(records))
+
+	parseErr.Locate([]byte("{\"a\": 1}\n{\"a\": 2}}\n"))
+	assert.Equal(t, 2, parseErr.Line)
+	assert.Equal(t, 9, parseErr.Column)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Paths address the nodes of the tree, like jq paths without the leading
+// dot: a.b[0] is the first element of the array b of the object a. Keys
+// that are not simple names are quoted like JSON strings, a["x.y"]["0 1"],
+// so that every node has a path of its own. Earlier occurrences of a
+// duplicate key have a #n suffix, a#1.
+
+// errPathNotFound is returned for a path that is valid but addresses no node
+var errPathNotFound = errors.New("not found")
+
+// Characters that end a simple key in a path
+const pathSpecials = `.[]"#`
+
+// pathSegment is one step of a path: a key, or an index in an array
+type pathSegment struct {
+	key       string
+	index     int
+	isIndex   bool
+	duplicate int // n of key#n, 0 for the last occurrence of a key
+}
+
+// isSimpleKey reports whether the key can be written without quotes
+func isSimpleKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, pathSpecials) &&
+		strings.IndexFunc(key, unicode.IsSpace) < 0
+}
+
+// quoteKey returns the ["key"] segment of a key that is not simple
+func quoteKey(key string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(key)
+	return "[" + strings.TrimSuffix(buf.String(), "\n") + "]"
+}
+
+func isPathSpecial(c byte) bool {
+	return strings.IndexByte(pathSpecials, c) >= 0 || unicode.IsSpace(rune(c))
+}
+
+// parsePath splits a path into its segments. The path may start with a
+// dot, as typed on the command line: .a["x.y"][0]. An empty path, or a
+// single dot, is the root.
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+
+	for i := 0; i < len(path); {
+		switch c := path[i]; {
+		case c == '.' || i == 0 && c != '[':
+			if c == '.' {
+				i++
+				if i == len(path) && len(segments) == 0 {
+					// . is the root
+					return nil, nil
+				}
+				if i < len(path) && path[i] == '[' {
+					// .["x.y"] and .[0]
+					continue
+				}
+			}
+			start := i
+			for i < len(path) && !isPathSpecial(path[i]) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("missing key at %d", start)
+			}
+			segments = append(segments, pathSegment{key: path[start:i]})
+
+		case c == '[':
+			i++
+			segment, n, err := parseBracket(path[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d", err, i)
+			}
+			segments = append(segments, segment)
+			i += n
+
+		case c == '#':
+			i++
+			start := i
+			for i < len(path) && path[i] >= '0' && path[i] <= '9' {
+				i++
+			}
+			n, err := strconv.Atoi(path[start:i])
+			last := len(segments) - 1
+			if err != nil || n == 0 || last < 0 || segments[last].isIndex {
+				return nil, fmt.Errorf("invalid duplicate number at %d", start)
+			}
+			segments[last].duplicate = n
+
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+
+	return segments, nil
+}
+
+// parseBracket parses what follows a "[": a quoted key or an index, and
+// the closing "]". It returns the segment and the number of bytes read.
+func parseBracket(s string) (pathSegment, int, error) {
+	var segment pathSegment
+	i := 0
+
+	if strings.HasPrefix(s, `"`) {
+		// Find the closing quote, skipping escaped characters
+		for i = 1; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' {
+				i++
+			}
+		}
+		if i >= len(s) {
+			return segment, 0, errors.New("unterminated key")
+		}
+		i++
+		if err := json.Unmarshal([]byte(s[:i]), &segment.key); err != nil {
+			return segment, 0, errors.New("invalid quoted key")
+		}
+	} else {
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		index, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return segment, 0, errors.New("expected an index or a quoted key")
+		}
+		segment.index, segment.isIndex = index, true
+	}
+
+	if i >= len(s) || s[i] != ']' {
+		return segment, 0, errors.New(`missing "]"`)
+	}
+	return segment, i + 1, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer, /a~1b/0, into segments.
+// Its tokens are keys; they are taken as indexes in arrays.
+func parsePointer(pointer string) ([]pathSegment, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New(`a JSON Pointer starts with "/"`)
+	}
+
+	var segments []pathSegment
+	for _, token := range strings.Split(pointer[1:], "/") {
+		if strings.Contains(strings.NewReplacer("~0", "", "~1", "").Replace(token), "~") {
+			return nil, fmt.Errorf("invalid escape in %q, only ~0 and ~1 are allowed", token)
+		}
+		key := strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		segments = append(segments, pathSegment{key: key})
+	}
+	return segments, nil
+}
+
+// ResolvePath returns the path of the node addressed by a path typed on
+// the command line, .a["x.y"][0], or by a JSON Pointer, /a/x.y/0
+func (jt *JSONTree) ResolvePath(input string) (string, error) {
+	id, err := jt.Resolve(input)
+	if err != nil {
+		return "", err
+	}
+	return jt.Path(id), nil
+}
+
+// Resolve returns the ID of the node addressed by a path or by a JSON
+// Pointer, like ResolvePath
+func (jt *JSONTree) Resolve(input string) (NodeID, error) {
+	parse := parsePath
+	if strings.HasPrefix(input, "/") {
+		parse = parsePointer
+	}
+	segments, err := parse(input)
+	if err != nil {
+		return noNode, err
+	}
+	return jt.resolve(segments)
+}
+
+// resolve follows the segments from the root
+func (jt *JSONTree) resolve(segments []pathSegment) (NodeID, error) {
+	if len(jt.nodes) == 0 {
+		return noNode, errPathNotFound
+	}
+
+	id := rootID
+	for _, segment := range segments {
+		var err error
+		if id, err = jt.child(id, segment); err != nil {
+			return noNode, err
+		}
+	}
+	if id == rootID && jt.Stream {
+		// The root of a stream is not a node of the document
+		return noNode, errPathNotFound
+	}
+	return id, nil
+}
+
+// child returns the child of a node that the segment addresses. Whether
+// a number is a key or an index depends on the node being an object or
+// an array.
+func (jt *JSONTree) child(id NodeID, segment pathSegment) (NodeID, error) {
+	if err := jt.load(id); err != nil {
+		return noNode, err
+	}
+
+	switch jt.nodes[id].Type {
+	case ArrayType:
+		index := segment.index
+		if !segment.isIndex {
+			var ok bool
+			if index, ok = arrayIndex(segment.key); !ok {
+				return noNode, fmt.Errorf("%w: %q is not an array index", errPathNotFound, segment.key)
+			}
+		}
+		if elements := jt.childIndex(id).elements; index < len(elements) {
+			return elements[index], nil
+		}
+
+	case ObjectType:
+		key := segment.key
+		if segment.isIndex {
+			key = strconv.Itoa(segment.index)
+		}
+		if child, exists := jt.childIndex(id).members[memberKey{key, segment.duplicate}]; exists {
+			return child, nil
+		}
+	}
+	return noNode, errPathNotFound
+}
+
+// arrayIndex reads a key used as an array index. Like in RFC 6901, it is
+// only digits, without a leading zero: 01 or +1 do not address element 1.
+func arrayIndex(key string) (int, bool) {
+	if key == "" || (key[0] == '0' && len(key) > 1) {
+		return 0, false
+	}
+	for _, c := range key {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(key)
+	return index, err == nil
+}
+
+// Pointer returns the RFC 6901 JSON Pointer of the node at path, or ""
+// for the root and for earlier occurrences of a duplicate key, which no
+// pointer can address
+func (jt *JSONTree) Pointer(path string) string {
+	id := jt.lookup(path)
+	if id == noNode {
+		return ""
+	}
+	return jt.pointer(id)
+}
+
+func (jt *JSONTree) pointer(id NodeID) string {
+	var tokens []string
+	for ; id != rootID; id = jt.parent[id] {
+		node := &jt.nodes[id]
+		token := node.Key
+		if node.IsArrayElement {
+			token = strings.Trim(node.Key, "[]")
+		} else if node.Occurrence > 0 {
+			return ""
+		}
+		tokens = append(tokens, strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+
+	if len(tokens) == 0 {
+		return ""
+	}
+	slices.Reverse(tokens)
+	return "/" + strings.Join(tokens, "/")
+}
+
+// displayPath returns the path of the node for the status bar, followed
+// by its JSON Pointer
+func (jt *JSONTree) displayPath(node *Node) string {
+	path := "." + jt.Path(node.ID)
+	if pointer := jt.pointer(node.ID); pointer != "" {
+		path += "  " + noteStyle.Render(pointer)
+	}
+	return path
+}
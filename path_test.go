@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected []pathSegment
+	}{
+		{"root", ".", nil},
+		{"empty", "", nil},
+		{"keys", ".a.b", []pathSegment{{key: "a"}, {key: "b"}}},
+		{"without dot", "a[0]", []pathSegment{{key: "a"}, {index: 0, isIndex: true}}},
+		{"quoted key", `.["a.b"].c`, []pathSegment{{key: "a.b"}, {key: "c"}}},
+		{"quoted escapes", `.a["say \"hi\"\n"]`, []pathSegment{{key: "a"}, {key: "say \"hi\"\n"}}},
+		{"root index", ".[2][10]", []pathSegment{{index: 2, isIndex: true}, {index: 10, isIndex: true}}},
+		{"duplicate", ".a#2.b", []pathSegment{{key: "a", duplicate: 2}, {key: "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := parsePath(tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, segments)
+		})
+	}
+
+	for _, path := range []string{`.["a.b"`, `.["a.b]`, ".a[x]", ".a.", "..a", ".a#", ".a#0", ".[0]#1", ".a b"} {
+		_, err := parsePath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	segments, err := parsePointer("/a~1b/0/~0x/")
+	assert.NoError(t, err)
+	assert.Equal(t, []pathSegment{{key: "a/b"}, {key: "0"}, {key: "~x"}, {key: ""}}, segments)
+
+	_, err = parsePointer("/a~2")
+	assert.Error(t, err)
+}
+
+func TestResolvePath(t *testing.T) {
+	tree := mustLoadTree(t, `{"a.b": 1, "a": {"b": [true, {"x/y": 2}]}, "": 3, "0": 4, "d": 5, "d": 6}`)
+
+	// Keys with dots have paths of their own
+	assert.Equal(t, []string{`["a.b"]`, "a", `[""]`, "0", "d#1", "d"}, tree.GetChildren(""))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{".", ""},
+		{`.["a.b"]`, `["a.b"]`},
+		{".a.b", "a.b"},
+		{`.a["b"][1]["x/y"]`, "a.b[1].x/y"},
+		{`.[""]`, `[""]`},
+		{`.["0"]`, "0"},
+		{".0", "0"},
+		{".d#1", "d#1"},
+		{"/a.b", `["a.b"]`},
+		{"/a/b/1/x~1y", "a.b[1].x/y"},
+		{"/", `[""]`},
+		{"/0", "0"},
+	}
+	for _, tt := range tests {
+		path, err := tree.ResolvePath(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, path, tt.input)
+	}
+
+	// An index has no leading zero or sign, like in RFC 6901
+	for _, input := range []string{".x", ".a.b[2]", "/a/b/x", ".a.b#1", "/a/b/-1", "/a/b/01", "/a/b/+1", "/a/b/00"} {
+		_, err := tree.ResolvePath(input)
+		assert.ErrorIs(t, err, errPathNotFound, input)
+	}
+
+	// Indexes of records and of root arrays
+	tree = mustLoadTree(t, `[{"a": 1}, {"a": 2}]`)
+	for _, input := range []string{".[1].a", "/1/a"} {
+		path, err := tree.ResolvePath(input)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.a", path)
+	}
+
+	// A key of an object may have a leading zero
+	tree = mustLoadTree(t, `{"01": [1]}`)
+	path, err := tree.ResolvePath("/01/0")
+	assert.NoError(t, err)
+	assert.Equal(t, "01[0]", path)
+}
+
+func TestPointer(t *testing.T) {
+	tree := mustLoadTree(t, `{"a/b": [{"~": 1}], "d": 1, "d": 2}`)
+
+	assert.Equal(t, "", tree.Pointer(""))
+	assert.Equal(t, "/a~1b/0/~0", tree.Pointer("a/b[0].~"))
+	assert.Equal(t, "/d", tree.Pointer("d"))
+	// No pointer tells the occurrences of a duplicate key apart
+	assert.Equal(t, "", tree.Pointer("d#1"))
+
+	// Every pointer leads back to its node
+	for _, path := range tree.GetAllPaths() {
+		if pointer := tree.Pointer(path); pointer != "" {
+			resolved, err := tree.ResolvePath(pointer)
+			assert.NoError(t, err)
+			assert.Equal(t, path, resolved)
+		}
+	}
+}
+
+func TestRunCommand_PathAndPointer(t *testing.T) {
+	currentTheme = themes["nocolor"]
+	m := newReloadModel(t, `{"a.b": {"c": 1}, "a": {"b": 2}}`)
+
+	run := func(command string) {
+		m.commandBuffer = command
+		updated, _ := m.runCommand()
+		m = updated.(model)
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		m = updated.(model)
+	}
+
+	run(`.["a.b"].c`)
+	node, _ := m.nodeAtCursor()
+	assert.Equal(t, `["a.b"].c`, m.tree.Path(node.ID))
+	assert.Equal(t, `.["a.b"].c  /a.b/c`, m.statusBar)
+
+	run("/a/b")
+	node, _ = m.nodeAtCursor()
+	assert.Equal(t, "a.b", m.tree.Path(node.ID))
+	assert.Equal(t, ".a.b  /a/b", m.statusBar)
+
+	m.commandBuffer = `.["a.b"`
+	updated, _ := m.runCommand()
+	m = updated.(model)
+	assert.Equal(t, Error, m.mode)
+	assert.Contains(t, m.statusBar, "Invalid path")
+
+	m.commandBuffer = "/x"
+	updated, _ = m.runCommand()
+	m = updated.(model)
+	assert.Equal(t, Error, m.mode)
+	assert.Contains(t, m.statusBar, "Path not found: /x")
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How often the file is checked for changes
+const watchInterval = time.Second
+
+// How long added, changed and removed nodes stay highlighted
+const changeHighlightDuration = 3 * time.Second
+
+// ChangeKind tells how a node differs from the previous load
+type ChangeKind int
+
+const (
+	Unchanged ChangeKind = iota
+	Added
+	Modified
+	Removed // one or more children of the node were removed
+)
+
+// fileState is what the watcher compares to notice a change on disk
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// fileCheckMsg asks the model to check the watched file for changes
+type fileCheckMsg struct{}
+
+// reloadMsg carries the tree parsed from the file after it changed
+type reloadMsg struct {
+	tree       *JSONTree
+	source     []byte
+	compressed compression
+	response   httpResponse // of a URL
+	format     string       // detected again for the output of a command
+	state      fileState
+	err        error
+}
+
+// clearChangesMsg ends the highlight of the changes of a reload
+type clearChangesMsg struct {
+	generation int
+}
+
+func watchFile() tea.Cmd {
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		return fileCheckMsg{}
+	})
+}
+
+// checkFile reloads the file when its size or modification time changed,
+// otherwise it waits for the next check
+func (m model) checkFile() (tea.Model, tea.Cmd) {
+	if m.filePath == "" {
+		// The file was replaced by the output of a command
+		return m, nil
+	}
+
+	state, err := statFile(m.filePath)
+	if err != nil || state == m.fileState {
+		// The file may be missing for a moment while it is replaced
+		return m, watchFile()
+	}
+
+	return m, loadFile(m.filePath, m.opts, state)
+}
+
+// loadFile reads and parses the file again in the background
+func loadFile(path string, opts options, state fileState) tea.Cmd {
+	return func() tea.Msg {
+		file, err := os.Open(path)
+		if err != nil {
+			return reloadMsg{state: state, err: err}
+		}
+		if isLazyFile(path, opts) {
+			// The tree reads the file when its nodes are unfolded
+			tree, err := openLazy(file, opts)
+			if tree == nil {
+				file.Close()
+			}
+			return reloadMsg{tree: tree, state: state, err: err}
+		}
+		defer file.Close()
+
+		input, compressed, err := readInput(file)
+		if err != nil {
+			return reloadMsg{state: state, err: err}
+		}
+		tree, err := loadTree(input, opts)
+		return reloadMsg{tree: tree, source: input, compressed: compressed,
+			state: state, err: err}
+	}
+}
+
+// fetchAgain makes the request of the document again in the background
+func fetchAgain(req httpRequest, opts options) tea.Cmd {
+	return func() tea.Msg {
+		input, compressed, response, err := fetch(req)
+		if err != nil {
+			return reloadMsg{err: err}
+		}
+		tree, err := loadTree(input, opts)
+		return reloadMsg{tree: tree, source: input, compressed: compressed,
+			response: response, err: err}
+	}
+}
+
+// reload loads the document again for r and :reload: the command is run
+// again, the request is made again for a URL, and a file is read again
+// even if it did not change
+func (m model) reload() (tea.Model, tea.Cmd) {
+	if m.loads != nil {
+		// The file is watched once it is loaded
+		m.statusBar = "Still loading the input"
+		return m, nil
+	}
+
+	switch {
+	case m.command != nil:
+		m.statusBar = "Running: " + m.command.line
+		return m, execCommand(*m.command, m.opts)
+	case m.request != nil:
+		m.statusBar = "Reloading " + m.request.url
+		return m, fetchAgain(*m.request, m.opts)
+	case m.filePath != "":
+		state, _ := statFile(m.filePath)
+		return m, loadFile(m.filePath, m.opts, state)
+	}
+
+	m.mode = Error
+	m.statusBar = errorStyle.Render("Error: Nothing to reload, the input was read from a pipe")
+	return m, nil
+}
+
+// watch checks the file for changes again later, when vj was opened on a
+// file
+func (m model) watch() tea.Cmd {
+	if m.filePath == "" {
+		return nil
+	}
+	return watchFile()
+}
+
+// applyReload replaces the tree with the one parsed after the file
+// changed. Folds, the cursor and the search results are matched by path,
+// so they stay on the same nodes even when lines were added or removed.
+func (m model) applyReload(msg reloadMsg) (tea.Model, tea.Cmd) {
+	m.fileState = msg.state
+	if msg.response.status != "" {
+		m.response = msg.response
+	}
+	if msg.format != "" {
+		m.opts.format = msg.format
+	}
+
+	var parseErr *ParseError
+	if msg.err != nil && !errors.As(msg.err, &parseErr) {
+		m.statusBar = errorStyle.Render("Error: Reload failed: " + msg.err.Error())
+		return m, m.watch()
+	}
+
+	if parseErr != nil && msg.source != nil {
+		parseErr.Locate(msg.source)
+	}
+	if parseErr != nil {
+
+		// While a file is being written it is often invalid for a
+		// moment, so keep showing the last valid tree
+		if m.mode != ParseFailed {
+			m.statusBar = errorStyle.Render("Error: Reload failed: " + parseErr.Error())
+			return m, m.watch()
+		}
+
+		m.parseErr = parseErr
+	} else {
+		m.parseErr = nil
+		if m.mode == ParseFailed {
+			m.mode = Normal
+		}
+	}
+	m.source = msg.source
+	m.compressed = msg.compressed
+
+	old := m.tree
+	tree := msg.tree
+	if tree == nil {
+		// Nothing was parsed, the document stays on screen behind the
+		// error
+		return m, m.watch()
+	}
+
+	cursor := noNode
+	var matches []NodeID
+	if old != nil && old.Len() > 0 {
+		if node, exists := m.nodeAtCursor(); exists {
+			cursor = node.ID
+		}
+
+		// Decode the strings that were decoded before, then the
+		// folds inside them can be kept too
+		for _, path := range old.DecodedPaths() {
+			tree.DecodeString(path)
+		}
+		matches = matchNodes(old, tree)
+		for id, collapsed := range old.collapsed {
+			if matches[id] != noNode {
+				tree.setCollapsed(matches[id], collapsed)
+			}
+		}
+		tree.Changes = diffTrees(old, tree, matches)
+	}
+	if old != nil {
+		old.Close()
+	}
+
+	m.tree = tree
+	m.changeGeneration++
+	if !m.ready {
+		return m, m.watch()
+	}
+	m.visibleLines2.UpdateContent2(m.tree)
+
+	// Put the cursor back on the same node, or on the closest parent
+	// that still exists when the node was removed
+	for id := cursor; id != noNode; id = old.Parent(id) {
+		if matches[id] == noNode {
+			continue
+		}
+		if virtualLine, found := m.findVirtualLine(matches[id]); found {
+			m.cursorY = virtualLine
+			break
+		}
+	}
+	m.cursorY = max(min(m.cursorY, m.visibleLines2.Len()-1), 0)
+
+	m.ScrollDown()
+	m.ScrollUp()
+	m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
+		m.visibleLines2.total)
+
+	// Search the new tree again, the matches may have moved
+	if m.searchResults != nil {
+		m.performSearch()
+	}
+
+	if m.mode == Normal {
+		m.statusBar = summarizeChanges(tree.Changes)
+	}
+
+	generation := m.changeGeneration
+	return m, tea.Batch(
+		m.watch(),
+		tea.Tick(changeHighlightDuration, func(time.Time) tea.Msg {
+			return clearChangesMsg{generation: generation}
+		}),
+	)
+}
+
+// clearChanges removes the highlight of the last reload, unless the file
+// was reloaded again in the meantime
+func (m model) clearChanges(msg clearChangesMsg) (tea.Model, tea.Cmd) {
+	if m.tree == nil || msg.generation != m.changeGeneration {
+		return m, nil
+	}
+
+	m.tree.Changes = nil
+	if m.ready {
+		m.visibleLines2.UpdateContent2(m.tree)
+		m.visibleLines2.UpdateVisibleLines2(m.visibleLines2.firstLine,
+			m.visibleLines2.total)
+	}
+	return m, nil
+}
+
+// matchNodes returns, for each node of the old tree, the node of the new
+// tree at the same path, or noNode. The trees are walked together: the
+// members of objects are matched by key, the elements of arrays and the
+// records of streams by index. The nodes of a big file that were read in
+// the old tree are read in the new one too.
+func matchNodes(old *JSONTree, tree *JSONTree) []NodeID {
+	matches := make([]NodeID, len(old.nodes))
+	for i := range matches {
+		matches[i] = noNode
+	}
+	if len(old.nodes) == 0 || len(tree.nodes) == 0 {
+		return matches
+	}
+
+	var match func(o, n NodeID)
+	match = func(o, n NodeID) {
+		matches[o] = n
+		oldNode, node := &old.nodes[o], &tree.nodes[n]
+		if oldNode.Type != node.Type {
+			return
+		}
+		if old.firstChild[o] != noNode {
+			tree.load(n)
+		}
+
+		switch node.Type {
+		case ArrayType:
+			child := tree.firstChild[n]
+			for oldChild := old.firstChild[o]; oldChild != noNode && child != noNode; oldChild = old.nextSibling[oldChild] {
+				match(oldChild, child)
+				child = tree.nextSibling[child]
+			}
+
+		case ObjectType:
+			members := make(map[memberKey]NodeID)
+			for child := tree.firstChild[n]; child != noNode; child = tree.nextSibling[child] {
+				members[memberKey{tree.nodes[child].Key, tree.nodes[child].Occurrence}] = child
+			}
+			for oldChild := old.firstChild[o]; oldChild != noNode; oldChild = old.nextSibling[oldChild] {
+				key := memberKey{old.nodes[oldChild].Key, old.nodes[oldChild].Occurrence}
+				if child, exists := members[key]; exists {
+					match(oldChild, child)
+				}
+			}
+		}
+	}
+	match(rootID, rootID)
+
+	return matches
+}
+
+// diffTrees compares two trees by path, with the matches of matchNodes.
+// Nodes that are new or have a different value are marked as added or
+// modified; for removed nodes the closest parent that still exists is
+// marked.
+func diffTrees(old *JSONTree, tree *JSONTree, matches []NodeID) map[NodeID]ChangeKind {
+	changes := make(map[NodeID]ChangeKind)
+	matched := make([]bool, len(tree.nodes))
+
+	old.walk(func(o NodeID) {
+		n := matches[o]
+		if n == noNode {
+			// Removed, mark the closest parent that is still there
+			for parent := old.Parent(o); parent != noNode; parent = old.Parent(parent) {
+				if n := matches[parent]; n != noNode {
+					if changes[n] == Unchanged && !(n == rootID && tree.Stream) {
+						changes[n] = Removed
+					}
+					break
+				}
+			}
+			return
+		}
+
+		matched[n] = true
+		oldNode, node := &old.nodes[o], &tree.nodes[n]
+		if oldNode.Type != node.Type {
+			changes[n] = Modified
+			return
+		}
+
+		if node.Type != ObjectType && node.Type != ArrayType &&
+			nodeValueToString(oldNode) != nodeValueToString(node) {
+			changes[n] = Modified
+		}
+	})
+
+	tree.walk(func(id NodeID) {
+		if !matched[id] {
+			changes[id] = Added
+		}
+	})
+
+	return changes
+}
+
+func summarizeChanges(changes map[NodeID]ChangeKind) string {
+	var added, modified, removed int
+	for _, kind := range changes {
+		switch kind {
+		case Added:
+			added++
+		case Modified:
+			modified++
+		case Removed:
+			removed++
+		}
+	}
+
+	if added+modified+removed == 0 {
+		return "Reloaded, no changes"
+	}
+	return fmt.Sprintf("Reloaded: %d added, %d changed, %d with removed children",
+		added, modified, removed)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustLoadTree(t *testing.T, input string) *JSONTree {
+	t.Helper()
+	tree, err := loadTree([]byte(input), options{})
+	assert.NoError(t, err)
+	return tree
+}
+
+func TestDiffTrees(t *testing.T) {
+	old := mustLoadTree(t, `{"a": 1, "b": {"c": true, "d": "x"}, "e": [1, 2]}`)
+	tree := mustLoadTree(t, `{"a": 2, "b": {"c": true}, "e": [1, 2, 3], "f": null}`)
+
+	changes := diffTrees(old, tree, matchNodes(old, tree))
+
+	assert.Equal(t, Modified, changes[tree.lookup("a")])
+	assert.Equal(t, Removed, changes[tree.lookup("b")])
+	assert.Equal(t, Unchanged, changes[tree.lookup("b.c")])
+	assert.Equal(t, Added, changes[tree.lookup("e[2]")])
+	assert.Equal(t, Added, changes[tree.lookup("f")])
+	assert.Equal(t, 4, len(changes))
+}
+
+func newReloadModel(t *testing.T, input string) model {
+	t.Helper()
+	m := model{tree: mustLoadTree(t, input)}
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	return updated.(model)
+}
+
+func TestApplyReload_KeepsStateByPath(t *testing.T) {
+	m := newReloadModel(t,
+		`{"a": 1, "b": {"c": true, "d": "x"}, "e": {"f": 1}}`)
+
+	// Fold "b" and put the cursor on "e.f"
+	m.tree.Collapse("b")
+	line, found := m.findVirtualLineForPath("e.f")
+	assert.True(t, found)
+	m.cursorY = line
+
+	// A new key before "b" moves every line down
+	tree := mustLoadTree(t,
+		`{"new": 0, "a": 1, "b": {"c": false, "d": "x"}, "e": {"f": 1}}`)
+	updated, _ := m.applyReload(reloadMsg{tree: tree})
+	m = updated.(model)
+
+	assert.True(t, m.tree.IsCollapsed("b"))
+	node, exists := m.nodeAtCursor()
+	assert.True(t, exists)
+	assert.Equal(t, "e.f", m.tree.Path(node.ID))
+	assert.Equal(t, Added, m.tree.Changes[m.tree.lookup("new")])
+	assert.Equal(t, Modified, m.tree.Changes[m.tree.lookup("b.c")])
+}
+
+func TestApplyReload_RemovedCursorNode(t *testing.T) {
+	m := newReloadModel(t, `{"a": 1, "b": {"c": true}}`)
+	line, _ := m.findVirtualLineForPath("b.c")
+	m.cursorY = line
+
+	updated, _ := m.applyReload(reloadMsg{tree: mustLoadTree(t, `{"a": 1, "b": {}}`)})
+	m = updated.(model)
+
+	// The cursor moves to the closest parent that still exists
+	node, exists := m.nodeAtCursor()
+	assert.True(t, exists)
+	assert.Equal(t, "b", m.tree.Path(node.ID))
+}
+
+func TestApplyReload_KeepsLastValidTree(t *testing.T) {
+	m := newReloadModel(t, `{"a": 1}`)
+
+	input := []byte(`{"a": `)
+	tree, err := loadTree(input, options{})
+	updated, _ := m.applyReload(reloadMsg{tree: tree, source: input, err: err})
+	m = updated.(model)
+
+	assert.Equal(t, Normal, m.mode)
+	assert.NotNil(t, m.tree.Node("a"))
+	assert.Contains(t, m.statusBar, "Reload failed")
+}
+
+func TestApplyReload_NothingParsed(t *testing.T) {
+	m := newReloadModel(t, `{"a": 1}`)
+	m.mode = ParseFailed
+
+	// The file became invalid from its first byte: the error is shown,
+	// the document can still be browsed
+	input := []byte(`x`)
+	tree, err := loadTree(input, options{})
+	assert.Nil(t, tree)
+	updated, _ := m.applyReload(reloadMsg{tree: tree, source: input, err: err})
+	m = updated.(model)
+
+	assert.Equal(t, ParseFailed, m.mode)
+	assert.Equal(t, 1, m.parseErr.Column)
+	assert.NotNil(t, m.tree.Node("a"))
+	assert.True(t, m.canBrowse())
+}
+
+func TestApplyReload_ChangesAreCleared(t *testing.T) {
+	m := newReloadModel(t, `{"a": 1}`)
+
+	updated, _ := m.applyReload(reloadMsg{tree: mustLoadTree(t, `{"a": 2}`)})
+	m = updated.(model)
+	assert.Equal(t, Modified, m.visibleLines2.linesOnScreen[1].Change)
+
+	updated, _ = m.clearChanges(clearChangesMsg{generation: m.changeGeneration})
+	m = updated.(model)
+	assert.Equal(t, Unchanged, m.visibleLines2.linesOnScreen[1].Change)
+}
+
+func TestCheckFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"a": 1}`), 0o644))
+	state, err := statFile(path)
+	assert.NoError(t, err)
+
+	m := model{filePath: path, fileState: state}
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"a": 1, "b": 2}`), 0o644))
+	_, cmd := m.checkFile()
+
+	msg, ok := cmd().(reloadMsg)
+	assert.True(t, ok, "A changed file should be reloaded")
+	assert.NoError(t, msg.err)
+	assert.Equal(t, []string{"a", "b"}, msg.tree.GetChildren(""))
+}
@@ -2,9 +2,15 @@ package main
 
 import (
 	"fmt"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
+// Numbers with a larger exponent are compared by their text only, the
+// exact value of 1e999999999 would not fit in memory
+const maxSearchExponent = 1000
+
 func (m *model) performSearch() {
 	if m.searchBuffer == "" {
 		return
@@ -12,10 +18,12 @@ func (m *model) performSearch() {
 
 	m.searchResults = []SearchMatch{}
 	searchTerm := strings.ToLower(m.searchBuffer)
+	numberTerm := strings.TrimSpace(m.searchBuffer)
+	number := parseSearchNumber(numberTerm)
 
 	// Search through all visible nodes
-	for virtualLine, realLine := range m.tree.VirtualToRealLines {
-		node, exists := m.tree.GetNodeAtLine(realLine)
+	for virtualLine := range m.tree.VisibleLen() {
+		node, exists := m.tree.GetNodeAtLine(m.tree.RealLine(virtualLine))
 		if !exists {
 			continue
 		}
@@ -24,7 +32,7 @@ func (m *model) performSearch() {
 		if node.Key != "" && strings.Contains(strings.ToLower(node.Key), searchTerm) {
 			m.searchResults = append(m.searchResults, SearchMatch{
 				VirtualLine: virtualLine,
-				Path:        node.Path,
+				Path:        m.tree.Path(node.ID),
 				MatchType:   "key",
 				Content:     node.Key,
 			})
@@ -33,10 +41,11 @@ func (m *model) performSearch() {
 		// Search in value (only for primitive types)
 		if node.Type != ObjectType && node.Type != ArrayType {
 			valueStr := nodeValueToString(node)
-			if valueStr != "" && strings.Contains(strings.ToLower(valueStr), searchTerm) {
+			if valueStr != "" && (strings.Contains(strings.ToLower(valueStr), searchTerm) ||
+				numberMatches(node, numberTerm, number)) {
 				m.searchResults = append(m.searchResults, SearchMatch{
 					VirtualLine: virtualLine,
-					Path:        node.Path,
+					Path:        m.tree.Path(node.ID),
 					MatchType:   "value",
 					Content:     valueStr,
 				})
@@ -130,6 +139,11 @@ func (m *model) updateSearchStatusBar() {
 		m.statusBar = fmt.Sprintf("/%s [%d/%d]",
 			m.searchBuffer, m.currentMatchIndex+1, len(m.searchResults))
 	}
+
+	// Reading the whole of a big file for a search would defeat the index
+	if m.tree.hasPending() {
+		m.statusBar += " (values not read yet are not searched)"
+	}
 }
 
 func nodeValueToString(node *Node) string {
@@ -139,7 +153,9 @@ func nodeValueToString(node *Node) string {
 			return str
 		}
 
-	case NumberType:
+	case IntegerType, FloatType:
+		// json.Number prints the original literal, so big
+		// integers and precise decimals are not rounded
 		return fmt.Sprintf("%v", node.Value)
 
 	case BoolType:
@@ -150,6 +166,11 @@ func nodeValueToString(node *Node) string {
 	case NullType:
 		return "null"
 
+	case DateTimeType:
+		if dt, ok := node.Value.(DateTime); ok {
+			return string(dt)
+		}
+
 	case ObjectType, ArrayType:
 		// For objects/arrays, we might want to search in their string representation
 		// or skip them entirely for basic search
@@ -158,3 +179,39 @@ func nodeValueToString(node *Node) string {
 
 	return fmt.Sprintf("%v", node.Value)
 }
+
+// numberMatches reports whether a number node has the same numeric value
+// as the search term, so that searching for 1e3 finds 1000 and 0.50 finds
+// 0.5. The comparison is exact, the literals are never rounded to float64.
+// number is the value of the term from parseSearchNumber, nil when the
+// term is not a number.
+func numberMatches(node *Node, term string, number *big.Rat) bool {
+	if number == nil || !node.Type.IsNumber() {
+		return false
+	}
+
+	literal := nodeValueToString(node)
+	if literal == term {
+		return true
+	}
+
+	value := parseSearchNumber(literal)
+	return value != nil && value.Cmp(number) == 0
+}
+
+// parseSearchNumber returns the exact value of a number literal, or nil
+// when it is not a number or its exponent is out of bounds
+func parseSearchNumber(s string) *big.Rat {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exponent, err := strconv.Atoi(s[i+1:])
+		if err != nil || exponent > maxSearchExponent || exponent < -maxSearchExponent {
+			return nil
+		}
+	}
+
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		term     string
+		expected bool
+	}{
+		{"same integer", json.Number("42"), "42", true},
+		{"exponent", json.Number("1000"), "1e3", true},
+		{"trailing zeros", json.Number("0.50"), "0.5", true},
+		{"big integer", json.Number("9007199254740993"), "9007199254740993", true},
+		{"rounded big integer", json.Number("9007199254740993"), "9007199254740992", false},
+		{"not a number", json.Number("42"), "forty", false},
+		{"huge exponent", json.Number("1"), "1e999999999", false},
+		{"huge exponent value", json.Number("1e-999999999"), "0", false},
+		{"string node", "42", "42", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &Node{Type: getNodeType(tt.value), Value: tt.value}
+			assert.Equal(t, tt.expected, numberMatches(node, tt.term, parseSearchNumber(tt.term)))
+		})
+	}
+}
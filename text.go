@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// textFragment is a JSON object or array found in text, with the text
+// around it
+type textFragment struct {
+	value  interface{}
+	line   int    // line of the input where the fragment starts
+	before string // text since the previous fragment
+	after  string // text after the fragment, up to the end of its line
+}
+
+// label describes where the fragment was found, the text that is not JSON
+// is kept but the fragment itself is replaced by …
+func (f textFragment) label() string {
+	parts := []string{fmt.Sprintf("line %d:", f.line)}
+	if f.before != "" {
+		parts = append(parts, f.before)
+	}
+	if f.after != "" {
+		parts = append(parts, "…", f.after)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ExtractJSON finds the JSON objects and arrays embedded in text, such as
+// log lines or an HTTP response with its headers. A candidate starts at
+// an opening brace or bracket and ends at the matching closing one; it is
+// a fragment when it is valid JSON, otherwise the search continues after
+// its first character.
+func ExtractJSON(src []byte) []textFragment {
+	var fragments []textFragment
+	ends := bracketEnds(src)
+	textStart := 0 // end of the previous fragment
+	afterEnd := 0  // end of its line, the end of its after text
+	line, lineCounted := 1, 0
+
+	for pos := 0; pos < len(src); pos++ {
+		if src[pos] != '{' && src[pos] != '[' {
+			continue
+		}
+		end, found := ends[pos]
+		if !found {
+			continue
+		}
+		value, err := ParseJSON(bytes.NewReader(src[pos:end]))
+		if err != nil {
+			continue
+		}
+
+		// The rest of the line of the previous fragment is its after
+		// text, unless this fragment is on the same line: then the
+		// text between the two is cut at this fragment
+		beforeStart := afterEnd
+		if n := len(fragments); n > 0 && pos < afterEnd {
+			fragments[n-1].after = flattenText(src[textStart:pos])
+			beforeStart = textStart
+		}
+
+		lineEnd := len(src)
+		if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+			lineEnd = end + i
+		}
+		line += bytes.Count(src[lineCounted:pos], []byte("\n"))
+		lineCounted = pos
+		fragments = append(fragments, textFragment{
+			value:  value,
+			line:   line,
+			before: flattenText(src[beforeStart:pos]),
+			after:  flattenText(src[end:lineEnd]),
+		})
+		textStart, afterEnd = end, lineEnd
+		pos = end - 1
+	}
+
+	// The text after the last fragment stays with it
+	if n := len(fragments); n > 0 {
+		if rest := flattenText(src[afterEnd:]); rest != "" {
+			last := &fragments[n-1]
+			last.after = strings.TrimSpace(last.after + " ⏎ " + rest)
+		}
+	}
+
+	return fragments
+}
+
+// bracketEnds returns the offset after the closing bracket of each opening
+// one, by the offset of the opening bracket, in one pass over the text.
+// The quotes of the text around the brackets are not strings, the
+// brackets inside the strings of a bracket are skipped. A bracket has no
+// end when the brackets in it are not balanced, or when a string in it
+// runs to the end of the line: a JSON string has no newline.
+func bracketEnds(src []byte) map[int]int {
+	ends := make(map[int]int)
+	var stack []int // offsets of the open brackets
+	inString, escaped := false, false
+
+	for i, c := range src {
+		switch {
+		case inString:
+			switch {
+			case c == '\n':
+				stack, inString = stack[:0], false
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"' && len(stack) > 0:
+			inString = true
+		case c == '{' || c == '[':
+			stack = append(stack, i)
+		case c == '}' || c == ']':
+			if len(stack) == 0 {
+				continue
+			}
+			// A closing bracket of the other kind ends all the open ones
+			if open := src[stack[len(stack)-1]]; (open == '{') != (c == '}') {
+				stack = stack[:0]
+				continue
+			}
+			ends[stack[len(stack)-1]] = i + 1
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return ends
+}
+
+// flattenText joins the non-empty lines of text, so it fits on the line
+// of a label
+func flattenText(text []byte) string {
+	var lines []string
+	for _, line := range strings.Split(string(text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " ⏎ ")
+}
+
+// decodeText reads the JSON embedded in text, each fragment is a record
+// labeled with the text around it
+func decodeText(input []byte, _ options) (*JSONTree, error) {
+	fragments := ExtractJSON(input)
+	if len(fragments) == 0 {
+		return nil, &ParseError{Err: errors.New("no JSON object or array in input")}
+	}
+
+	records := make(Stream, len(fragments))
+	for i, fragment := range fragments {
+		records[i] = annotate(fragment.value, []string{fragment.label()})
+	}
+	return BuildTree(records, "", nil), nil
+}
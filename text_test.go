@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractJSON_Logs(t *testing.T) {
+	input := "2024-01-01 INFO start\n" +
+		`2024-01-01 INFO request body={"id": 1, "tags": ["a]"]} took=3ms` + "\n" +
+		"2024-01-01 WARN [retry] payload=[1, 2]\n"
+
+	fragments := ExtractJSON([]byte(input))
+	assert.Len(t, fragments, 2)
+
+	assert.Equal(t, Object{
+		{Key: "id", Value: json.Number("1")},
+		{Key: "tags", Value: []interface{}{"a]"}},
+	}, fragments[0].value)
+	assert.Equal(t, 2, fragments[0].line)
+	assert.Equal(t, "line 2: 2024-01-01 INFO start ⏎ 2024-01-01 INFO request body= … took=3ms",
+		fragments[0].label())
+
+	// [retry] is not JSON, the search goes on after it
+	assert.Equal(t, []interface{}{json.Number("1"), json.Number("2")}, fragments[1].value)
+	assert.Equal(t, "line 3: 2024-01-01 WARN [retry] payload=", fragments[1].label())
+}
+
+func TestExtractJSON_SameLine(t *testing.T) {
+	input := `INFO req={"a":1} resp={"b":2}` + "\n" + `DEBUG x=[3] done` + "\nbye\n"
+
+	fragments := ExtractJSON([]byte(input))
+	assert.Len(t, fragments, 3)
+
+	// The text between two fragments of a line is cut at the second one
+	assert.Equal(t, "line 1: INFO req= … resp=", fragments[0].label())
+	assert.Equal(t, "line 1: resp=", fragments[1].label())
+	assert.Equal(t, Object{{Key: "b", Value: json.Number("2")}}, fragments[1].value)
+
+	// The text after a fragment stays with it, and the lines after the
+	// last one too
+	assert.Equal(t, "line 2: DEBUG x= … done ⏎ bye", fragments[2].label())
+}
+
+func TestExtractJSON_Brackets(t *testing.T) {
+	input := `ERROR "quoted [text" {"a": "}]", "b": [1, {"c": 2}}` + "\n" +
+		`WARN {"open": "no end` + "\n" + `INFO {"d": [3]} ok` + "\n"
+
+	// The brackets in strings are skipped. A candidate that is not valid
+	// JSON is not a fragment, the brackets in it are tried.
+	fragments := ExtractJSON([]byte(input))
+	assert.Len(t, fragments, 2)
+	assert.Equal(t, Object{{Key: "c", Value: json.Number("2")}}, fragments[0].value)
+	assert.Equal(t, 1, fragments[0].line)
+
+	// A string that runs to the end of the line ends its brackets
+	assert.Equal(t, Object{{Key: "d", Value: []interface{}{json.Number("3")}}}, fragments[1].value)
+	assert.Equal(t, 3, fragments[1].line)
+}
+
+func TestExtractJSON_HTTPResponse(t *testing.T) {
+	input := "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n" +
+		"{\n  \"ok\": true\n}\n"
+
+	tree, err := loadTree([]byte(input), options{format: "text"})
+	assert.NoError(t, err)
+
+	// Each fragment is a record, even when there is only one
+	assert.True(t, tree.Stream)
+	assert.Equal(t, []string{"0"}, tree.GetChildren(""))
+	assert.Equal(t, true, mustGetValue(t, tree, "0.ok"))
+	assert.Equal(t, "line 4: HTTP/1.1 200 OK ⏎ Content-Type: application/json",
+		tree.Node("0").Note)
+}
+
+func TestExtractJSON_NoFragment(t *testing.T) {
+	tree, err := loadTree([]byte("nothing {here\n"), options{format: "text"})
+	assert.Nil(t, tree)
+	assert.EqualError(t, err, "byte 0: no JSON object or array in input")
+}
@@ -14,9 +14,16 @@ var (
 	nullStyle      lipgloss.Style
 	booleanStyle   lipgloss.Style
 	numberStyle    lipgloss.Style
+	dateTimeStyle  lipgloss.Style
+	bytesStyle     lipgloss.Style
 	syntaxStyle    lipgloss.Style
 	statusBarStyle lipgloss.Style
 	errorStyle     lipgloss.Style
+	addedStyle     lipgloss.Style
+	modifiedStyle  lipgloss.Style
+	removedStyle   lipgloss.Style
+	noteStyle      lipgloss.Style
+	warningStyle   lipgloss.Style
 )
 
 type Color string
@@ -29,9 +36,16 @@ type Theme struct {
 	Null       Color
 	Boolean    Color
 	Number     Color
+	DateTime   Color
+	Bytes      Color
 	LineNumber Color
 	Syntax     Color
 	Error      Color
+	Added      Color
+	Modified   Color
+	Removed    Color
+	Note       Color
+	Warning    Color
 }
 
 var (
@@ -44,9 +58,16 @@ var (
 	defaultNull       = Color("#565f89")
 	defaultBoolean    = Color("#ff9e64")
 	defaultNumber     = Color("#ff9e64")
+	defaultDateTime   = Color("#2ac3de")
+	defaultBytes      = Color("#9d7cd8")
 	defaultLineNumber = Color("#565f89")
 	defaultSyntax     = Color("")
 	defaultError      = Color("9")
+	defaultAdded      = Color("#73daca")
+	defaultModified   = Color("#e0af68")
+	defaultRemoved    = Color("#f7768e")
+	defaultNote       = Color("#565f89")
+	defaultWarning    = Color("#e0af68")
 )
 
 var themes = map[string]Theme{
@@ -58,6 +79,8 @@ var themes = map[string]Theme{
 		Null:       Color(""),
 		Boolean:    Color(""),
 		Number:     Color(""),
+		DateTime:   Color(""),
+		Bytes:      Color(""),
 		LineNumber: Color(""),
 	},
 	"dark": {
@@ -68,9 +91,16 @@ var themes = map[string]Theme{
 		Null:       defaultNull,
 		Boolean:    defaultBoolean,
 		Number:     defaultNumber,
+		DateTime:   defaultDateTime,
+		Bytes:      defaultBytes,
 		LineNumber: defaultLineNumber,
 		Syntax:     defaultSyntax,
 		Error:      defaultError,
+		Added:      defaultAdded,
+		Modified:   defaultModified,
+		Removed:    defaultRemoved,
+		Note:       defaultNote,
+		Warning:    defaultWarning,
 	},
 	"light": {
 		Cursor:     Color("#0066cc"),
@@ -80,8 +110,15 @@ var themes = map[string]Theme{
 		Null:       Color("#6f42c1"),
 		Boolean:    Color("#d73a49"),
 		Number:     Color("#005cc5"),
+		DateTime:   Color("#e36209"),
+		Bytes:      Color("#5a32a3"),
 		LineNumber: Color("#586069"),
 		Error:      Color("9"),
+		Added:      Color("#22863a"),
+		Modified:   Color("#b08800"),
+		Removed:    Color("#d73a49"),
+		Note:       Color("#6a737d"),
+		Warning:    Color("#b08800"),
 	},
 }
 
@@ -111,6 +148,12 @@ func SetCurrentTheme(name string) {
 	numberStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(currentTheme.Number))
 
+	dateTimeStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.DateTime))
+
+	bytesStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.Bytes))
+
 	syntaxStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(currentTheme.Syntax))
 
@@ -124,6 +167,33 @@ func SetCurrentTheme(name string) {
 	errorStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(currentTheme.Error))
 
+	addedStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.Added))
+
+	modifiedStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.Modified))
+
+	removedStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.Removed))
+
+	noteStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.Note))
+
+	warningStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(currentTheme.Warning))
+}
+
+// RenderChange renders the gutter mark of a node that changed on reload
+func RenderChange(change ChangeKind) string {
+	switch change {
+	case Added:
+		return addedStyle.Render("+")
+	case Modified:
+		return modifiedStyle.Render("~")
+	case Removed:
+		return removedStyle.Render("-")
+	}
+	return " "
 }
 
 func RenderIndent(text string, selected bool) string {
@@ -158,6 +228,16 @@ func RenderNumber(text string, hasCursor bool, isSelected bool) string {
 		text, hasCursor, isSelected, numberStyle)
 }
 
+func RenderDateTime(text string, hasCursor bool, isSelected bool) string {
+	return RenderElement(
+		text, hasCursor, isSelected, dateTimeStyle)
+}
+
+func RenderBytes(text string, hasCursor bool, isSelected bool) string {
+	return RenderElement(
+		text, hasCursor, isSelected, bytesStyle)
+}
+
 func RenderBoolean(text string, hasCursor bool, isSelected bool) string {
 	return RenderElement(
 		text, hasCursor, isSelected, booleanStyle)
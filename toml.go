@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// How a TOML table was created, which decides whether it can be
+// defined again
+type tableOrigin int
+
+const (
+	implicitTable tableOrigin = iota // parent of a [a.b] header
+	headerTable                      // [a] header
+	dottedTable                      // dotted key, a.b = 1
+	inlineTable                      // a = { b = 1 }, cannot be extended
+)
+
+// tomlTable is a table being built. Its keys keep the document order.
+type tomlTable struct {
+	keys   []string
+	values map[string]interface{} // *tomlTable, *tomlTableArray or a value
+	origin tableOrigin
+	note   string
+}
+
+// tomlTableArray is an array of tables, [[a]]
+type tomlTableArray struct {
+	tables []*tomlTable
+}
+
+func newTOMLTable(origin tableOrigin) *tomlTable {
+	return &tomlTable{values: make(map[string]interface{}), origin: origin}
+}
+
+func (t *tomlTable) set(key string, value interface{}) {
+	if _, exists := t.values[key]; !exists {
+		t.keys = append(t.keys, key)
+	}
+	t.values[key] = value
+}
+
+// ParseTOML parses a TOML document. Tables become objects with their keys
+// in document order, arrays of tables become arrays of objects, and dates
+// and times are DateTime values. Comments are kept as notes of the key or
+// table that follows them or that they end the line of.
+//
+// On invalid input it returns a *ParseError and the tables and keys read
+// before the error.
+func ParseTOML(src []byte) (interface{}, error) {
+	p := unstable.Parser{KeepComments: true}
+	p.Reset(src)
+
+	root := newTOMLTable(headerTable)
+	current := root
+	var comments []string
+
+	for p.NextExpression() {
+		expr := p.Expression()
+
+		if expr.Kind == unstable.Comment {
+			comments = append(comments, tomlComment(expr))
+			continue
+		}
+
+		// A comment at the end of the line is chained to the expression
+		if next := expr.Next(); next != nil && next.Kind == unstable.Comment {
+			comments = append(comments, tomlComment(next))
+		}
+
+		key, offset := tomlKey(expr.Key())
+
+		var err error
+		switch expr.Kind {
+		case unstable.Table:
+			current, err = defineTable(root, key)
+			if err == nil {
+				current.note = strings.Join(comments, " ")
+			}
+
+		case unstable.ArrayTable:
+			current, err = appendTable(root, key)
+			if err == nil {
+				current.note = strings.Join(comments, " ")
+			}
+
+		case unstable.KeyValue:
+			err = setKeyValue(current, expr, comments)
+		}
+
+		if err != nil {
+			return root.value(), &ParseError{Err: err, Offset: offset}
+		}
+		comments = nil
+	}
+
+	if err := p.Error(); err != nil {
+		return root.value(), tomlParseError(&p, err)
+	}
+
+	return root.value(), nil
+}
+
+// defineTable creates the table of a [a.b.c] header and returns it
+func defineTable(root *tomlTable, key []string) (*tomlTable, error) {
+	parent, err := walkTables(root, key[:len(key)-1], implicitTable)
+	if err != nil {
+		return nil, err
+	}
+
+	name := key[len(key)-1]
+	switch existing := parent.values[name].(type) {
+	case nil:
+		table := newTOMLTable(headerTable)
+		parent.set(name, table)
+		return table, nil
+
+	case *tomlTable:
+		// A table created as the parent of another header can be
+		// defined later, once
+		if existing.origin == implicitTable {
+			existing.origin = headerTable
+			return existing, nil
+		}
+	}
+
+	return nil, fmt.Errorf("table %s is already defined", strings.Join(key, "."))
+}
+
+// appendTable adds a table to the [[a.b]] array of tables and returns it
+func appendTable(root *tomlTable, key []string) (*tomlTable, error) {
+	parent, err := walkTables(root, key[:len(key)-1], implicitTable)
+	if err != nil {
+		return nil, err
+	}
+
+	name := key[len(key)-1]
+	array, ok := parent.values[name].(*tomlTableArray)
+	if !ok {
+		if _, exists := parent.values[name]; exists {
+			return nil, fmt.Errorf("%s is not an array of tables", strings.Join(key, "."))
+		}
+		array = &tomlTableArray{}
+		parent.set(name, array)
+	}
+
+	table := newTOMLTable(headerTable)
+	array.tables = append(array.tables, table)
+	return table, nil
+}
+
+// setKeyValue adds a key = value expression to the table
+func setKeyValue(table *tomlTable, expr *unstable.Node, comments []string) error {
+	key, _ := tomlKey(expr.Key())
+
+	parent, err := walkTables(table, key[:len(key)-1], dottedTable)
+	if err != nil {
+		return err
+	}
+
+	value, err := convertTOML(expr.Value())
+	if err != nil {
+		return err
+	}
+	if len(comments) > 0 {
+		value = annotate(value, comments)
+	}
+
+	name := key[len(key)-1]
+	if _, exists := parent.values[name]; exists {
+		return fmt.Errorf("key %s is already defined", strings.Join(key, "."))
+	}
+	parent.set(name, value)
+	return nil
+}
+
+// walkTables returns the table at the key path below table, creating the
+// missing tables. In an array of tables the last table is used.
+func walkTables(table *tomlTable, key []string, origin tableOrigin) (*tomlTable, error) {
+	for i, name := range key {
+		switch next := table.values[name].(type) {
+		case nil:
+			child := newTOMLTable(origin)
+			table.set(name, child)
+			table = child
+
+		case *tomlTable:
+			if next.origin == inlineTable {
+				return nil, fmt.Errorf("inline table %s cannot be extended",
+					strings.Join(key[:i+1], "."))
+			}
+			table = next
+
+		case *tomlTableArray:
+			table = next.tables[len(next.tables)-1]
+
+		default:
+			return nil, fmt.Errorf("key %s is not a table", strings.Join(key[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+// convertTOML converts a value node to the values used by BuildTree
+func convertTOML(node *unstable.Node) (interface{}, error) {
+	literal := string(node.Data)
+
+	switch node.Kind {
+	case unstable.String:
+		return literal, nil
+
+	case unstable.Bool:
+		return literal == "true", nil
+
+	case unstable.Integer:
+		n, ok := new(big.Int).SetString(literal, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %s", literal)
+		}
+		return json.Number(n.String()), nil
+
+	case unstable.Float:
+		return tomlFloat(literal)
+
+	case unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		return DateTime(literal), nil
+
+	case unstable.Array:
+		arr := []interface{}{}
+		it := node.Children()
+		for it.Next() {
+			if it.Node().Kind == unstable.Comment {
+				continue
+			}
+			value, err := convertTOML(it.Node())
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+
+	case unstable.InlineTable:
+		table := newTOMLTable(inlineTable)
+		it := node.Children()
+		for it.Next() {
+			if err := setKeyValue(table, it.Node(), nil); err != nil {
+				return nil, err
+			}
+		}
+		return table, nil
+	}
+
+	return nil, fmt.Errorf("unsupported TOML value %s", node.Kind)
+}
+
+// tomlFloat writes a TOML float as a JSON number literal
+func tomlFloat(literal string) (json.Number, error) {
+	number := strings.TrimPrefix(strings.ReplaceAll(literal, "_", ""), "+")
+
+	switch number {
+	case "inf":
+		return "Infinity", nil
+	case "-inf":
+		return "-Infinity", nil
+	case "nan", "-nan":
+		return "NaN", nil
+	}
+
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return "", fmt.Errorf("invalid float %s", literal)
+	}
+	return json.Number(number), nil
+}
+
+// tomlKey returns the parts of a dotted key and the offset of the key
+func tomlKey(it unstable.Iterator) ([]string, int64) {
+	var key []string
+	var offset int64
+	for it.Next() {
+		if key == nil {
+			offset = int64(it.Node().Raw.Offset)
+		}
+		key = append(key, string(it.Node().Data))
+	}
+	return key, offset
+}
+
+func tomlComment(node *unstable.Node) string {
+	return strings.Join(strings.Fields(string(node.Data)), " ")
+}
+
+// tomlParseError turns an error of the TOML parser into a *ParseError
+func tomlParseError(p *unstable.Parser, err error) *ParseError {
+	parseErr := &ParseError{Err: err, Offset: int64(len(p.Data()))}
+
+	var tomlErr *unstable.ParserError
+	if errors.As(err, &tomlErr) && tomlErr.Highlight != nil {
+		parseErr.Offset = int64(p.Range(tomlErr.Highlight).Offset)
+	}
+	return parseErr
+}
+
+// value converts the table to an Object, with its note
+func (t *tomlTable) value() interface{} {
+	obj := Object{}
+	for _, key := range t.keys {
+		obj = append(obj, Member{Key: key, Value: tomlValue(t.values[key])})
+	}
+
+	if t.note != "" {
+		return Annotated{Value: obj, Note: t.note}
+	}
+	return obj
+}
+
+func tomlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *tomlTable:
+		return value.value()
+
+	case *tomlTableArray:
+		arr := []interface{}{}
+		for _, table := range value.tables {
+			arr = append(arr, table.value())
+		}
+		return arr
+
+	case []interface{}:
+		// Arrays may hold inline tables
+		arr := make([]interface{}, len(value))
+		for i, item := range value {
+			arr[i] = tomlValue(item)
+		}
+		return arr
+
+	case Annotated:
+		return Annotated{Value: tomlValue(value.Value), Note: value.Note}
+	}
+
+	return value
+}
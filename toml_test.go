@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTOML(t *testing.T) {
+	input := `name = "vj"
+version = 0x10
+ratio = 1_000.5
+big = +inf
+released = 1979-05-27T07:32:00Z
+tags = ["a", "b"]
+owner.name = "Tom"
+
+[server]
+host = "localhost"
+ports = [8000, 8001]
+point = { x = 1, y = 2 }
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+`
+	tree, err := loadTree([]byte(input), options{format: "toml"})
+	assert.NoError(t, err)
+
+	// Keys keep the order of the document
+	assert.Equal(t, []string{"name", "version", "ratio", "big", "released",
+		"tags", "owner", "server", "products"}, tree.GetChildren(""))
+
+	assert.Equal(t, "vj", mustGetValue(t, tree, "name"))
+	assert.Equal(t, json.Number("16"), mustGetValue(t, tree, "version"))
+	assert.Equal(t, json.Number("1000.5"), mustGetValue(t, tree, "ratio"))
+	assert.Equal(t, json.Number("Infinity"), mustGetValue(t, tree, "big"))
+	assert.Equal(t, "Tom", mustGetValue(t, tree, "owner.name"))
+
+	// Tables are objects, arrays of tables are arrays of objects
+	assert.Equal(t, ObjectType, tree.Node("server").Type)
+	assert.Equal(t, json.Number("8001"), mustGetValue(t, tree, "server.ports[1]"))
+	assert.Equal(t, json.Number("2"), mustGetValue(t, tree, "server.point.y"))
+	assert.Equal(t, ArrayType, tree.Node("products").Type)
+	assert.Equal(t, "Nail", mustGetValue(t, tree, "products[1].name"))
+}
+
+func TestParseTOML_DateTime(t *testing.T) {
+	input := "odt = 1979-05-27T07:32:00-08:00\nld = 1979-05-27\nlt = 07:32:00\n"
+
+	tree, err := loadTree([]byte(input), options{format: "toml"})
+	assert.NoError(t, err)
+
+	for _, path := range []string{"odt", "ld", "lt"} {
+		assert.Equal(t, DateTimeType, tree.Node(path).Type)
+	}
+	assert.Equal(t, DateTime("1979-05-27"), mustGetValue(t, tree, "ld"))
+
+	// The value is shown as written, without quotes
+	currentTheme = themes["nocolor"]
+	lines := tree.PrintAsJSON2()
+	assert.Equal(t, `  "lt": 07:32:00`, RenderLine(lines[3], false))
+}
+
+func TestParseTOML_Comments(t *testing.T) {
+	input := "# The server\n[server]\nport = 80 # default\n"
+
+	tree, err := loadTree([]byte(input), options{format: "toml"})
+	assert.NoError(t, err)
+	assert.Equal(t, "# The server", tree.Node("server").Note)
+	assert.Equal(t, "# default", tree.Node("server.port").Note)
+}
+
+func TestParseTOML_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		message string
+	}{
+		{"duplicate key", "a = 1\na = 2\n", "key a is already defined"},
+		{"duplicate table", "[a]\n[a]\n", "table a is already defined"},
+		{"not a table", "a = 1\n[a.b]\n", "key a is not a table"},
+		{"inline table", "a = { b = 1 }\na.c = 2\n", "inline table a cannot be extended"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTOML([]byte(tt.input))
+			assert.EqualError(t, errors.Unwrap(err), tt.message)
+		})
+	}
+}
+
+func TestParseTOML_SyntaxError(t *testing.T) {
+	input := "a = 1\nb = [1, 2\n"
+
+	tree, err := loadTree([]byte(input), options{format: "toml"})
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	parseErr.Locate([]byte(input))
+	assert.Equal(t, 2, parseErr.Line)
+
+	// The keys before the error can be browsed
+	assert.Equal(t, json.Number("1"), mustGetValue(t, tree, "a"))
+}
@@ -1,139 +1,488 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"regexp"
+	"io"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// NodeID is the index of a node in the arrays of the tree
+type NodeID int32
+
+// noNode stands for a missing node: the parent of the root, the first
+// child of a leaf or the next sibling of a last child
+const noNode NodeID = -1
+
+// rootID is the root node. A stream has one too, without a line of its
+// own, and its children are the records.
+const rootID NodeID = 0
+
+// JSONTree holds the nodes in arrays indexed by their ID, and the shape
+// of the tree in the parent, first child and next sibling of each node.
+// Paths are built from the keys when they are needed.
 type JSONTree struct {
-	Nodes              map[string]*Node `json:"nodes"`
-	LineNumbers        map[int]*Node    `json:"lineNumbers"`
+	nodes              []Node
+	parent             []NodeID
+	firstChild         []NodeID
+	lastChild          []NodeID
+	nextSibling        []NodeID
+	collapsed          []bool
+	lines              lineIndex // lines of the unfolded document, and which are shown
+	splicing           bool      // the lines added go to spliced, see spliceLines
+	spliced            []int32
+	index              map[NodeID]*childIndex
+	source             io.ReaderAt // input of a lazy tree, see loadLazy
+	spans              []pending   // containers whose children are not built yet
 	VirtualToRealLines []int
-	Children           map[string][]string `json:"children"`
-	Collapsed          map[string]bool     `json:"collapsed"`
-	lineCounter        int
+	Stream             bool
+	Changes            map[NodeID]ChangeKind
+	Duplicates         []NodeID // members with a duplicate key
+	removed            int      // nodes removed by RestoreString, their IDs are not reused
+	loading            bool     // members are still added to the root, see applyBatch
 	currentRealLine    int
 }
 
+// lineRef is a line of the document: the line of a node, or the line of
+// the closing bracket of an object or an array
+type lineRef struct {
+	node    NodeID
+	closing bool
+}
+
+// memberKey tells the members of an object apart, including the
+// occurrences of a duplicate key
+type memberKey struct {
+	key        string
+	occurrence int
+}
+
+// childIndex finds the children of a container without walking its
+// siblings, it is built the first time a path goes through it
+type childIndex struct {
+	elements []NodeID
+	members  map[memberKey]NodeID
+}
+
 func NewJSONTree() *JSONTree {
-	return &JSONTree{
-		Nodes:       make(map[string]*Node),
-		LineNumbers: make(map[int]*Node),
-		Children:    make(map[string][]string),
-		Collapsed:   make(map[string]bool),
-		lineCounter: 0,
-	}
+	return &JSONTree{}
 }
 
 // ========== Core methods ==========
 
-// GetValue returns the value at the given path
-func (jt *JSONTree) GetValue(path string) interface{} {
-	if node, exists := jt.Nodes[path]; exists {
-		return node.Value
+// GetValue returns the value at the given path. Objects and arrays are
+// built again from their children, the ones of a big file are read from
+// it, which may fail.
+func (jt *JSONTree) GetValue(path string) (interface{}, error) {
+	if id := jt.lookup(path); id != noNode {
+		return jt.value(id)
+	}
+	return nil, nil
+}
+
+// value returns the value of a node, an Object or an []interface{} for
+// objects and arrays
+func (jt *JSONTree) value(id NodeID) (interface{}, error) {
+	if err := jt.load(id); err != nil {
+		return nil, err
 	}
-	return nil
+
+	switch jt.nodes[id].Type {
+	case ObjectType:
+		obj := Object{}
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			value, err := jt.value(child)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, Member{Key: jt.nodes[child].Key, Value: value})
+		}
+		return obj, nil
+	case ArrayType:
+		values := []interface{}{}
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			value, err := jt.value(child)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return jt.nodes[id].Value, nil
 }
 
 // Collapse marks a path as collapsed
 func (jt *JSONTree) Collapse(path string) {
-	jt.Collapsed[path] = true
+	if id := jt.lookup(path); id != noNode {
+		jt.setCollapsed(id, true)
+	}
 }
 
 // Expand marks a path as expanded
 func (jt *JSONTree) Expand(path string) {
-	delete(jt.Collapsed, path)
+	if id := jt.lookup(path); id != noNode {
+		jt.setCollapsed(id, false)
+	}
 }
 
 // IsCollapsed checks if a path is collapsed
 func (jt *JSONTree) IsCollapsed(path string) bool {
-	return jt.Collapsed[path]
+	id := jt.lookup(path)
+	return id != noNode && jt.collapsed[id]
 }
 
-// AddChild adds a child path to a parent
-func (jt *JSONTree) AddChild(parent string, child string) {
-	if jt.Children[parent] == nil {
-		// Create empty slice to list the children nodes
-		jt.Children[parent] = make([]string, 0)
+// AddChild adds the node as the last child of parent, or as the root
+// when parent is noNode, and returns its ID
+func (jt *JSONTree) AddChild(parent NodeID, node Node) NodeID {
+	id := NodeID(len(jt.nodes))
+	node.ID = id
+	jt.nodes = append(jt.nodes, node)
+	jt.parent = append(jt.parent, parent)
+	jt.firstChild = append(jt.firstChild, noNode)
+	jt.lastChild = append(jt.lastChild, noNode)
+	jt.nextSibling = append(jt.nextSibling, noNode)
+	jt.collapsed = append(jt.collapsed, false)
+
+	if parent != noNode {
+		if jt.index != nil {
+			delete(jt.index, parent)
+		}
+		if last := jt.lastChild[parent]; last != noNode {
+			jt.nextSibling[last] = id
+		} else {
+			jt.firstChild[parent] = id
+		}
+		jt.lastChild[parent] = id
 	}
-	jt.Children[parent] = append(jt.Children[parent], child)
+	return id
+}
+
+// AppendRecord adds value as the next top-level record of a stream.
+// Records have no root node, their paths are their position in the
+// stream, like the elements of a top-level array.
+func (jt *JSONTree) AppendRecord(value interface{}) *Node {
+	if len(jt.nodes) == 0 {
+		// The root of a stream has no line
+		jt.AddChild(noNode, Node{Type: ArrayType})
+	}
+	jt.Stream = true
+
+	index := 0
+	if last := jt.lastChild[rootID]; last != noNode {
+		index = elementIndex(&jt.nodes[last]) + 1
+	}
+	id := jt.newNode(rootID, value, fmt.Sprintf("[%d]", index), true)
+	if isNested(value) {
+		jt.buildChildren(id, value)
+	}
+
+	return &jt.nodes[id]
 }
 
 // ========== Utility Methods ==========
 
+// Len returns the number of nodes in the tree
+func (jt *JSONTree) Len() int {
+	n := len(jt.nodes) - jt.removed
+	if jt.Stream {
+		n--
+	}
+	return n
+}
+
 // GetNode returns the node at the given path
 func (jt *JSONTree) GetNode(path string) (*Node, bool) {
-	node, exists := jt.Nodes[path]
-	return node, exists
+	if id := jt.lookup(path); id != noNode {
+		return &jt.nodes[id], true
+	}
+	return nil, false
+}
+
+// Node returns the node at the given path, or nil
+func (jt *JSONTree) Node(path string) *Node {
+	node, _ := jt.GetNode(path)
+	return node
 }
 
 // GetNodeAtLine returns the node at a given line number
 func (jt *JSONTree) GetNodeAtLine(lineNum int) (*Node, bool) {
-	node, exists := jt.LineNumbers[lineNum]
-	return node, exists
+	if lineNum < 0 || lineNum >= jt.lines.len() {
+		return nil, false
+	}
+	ref := jt.lines.ref(jt.lines.at(lineNum))
+	if ref.closing {
+		return nil, false
+	}
+	return &jt.nodes[ref.node], true
+}
+
+// Parent returns the ID of the parent of a node, noNode for the root
+func (jt *JSONTree) Parent(id NodeID) NodeID {
+	return jt.parent[id]
+}
+
+// children returns the IDs of the children of a node
+func (jt *JSONTree) children(id NodeID) []NodeID {
+	var ids []NodeID
+	for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+		ids = append(ids, child)
+	}
+	return ids
+}
+
+// childCount returns the number of children of a node
+func (jt *JSONTree) childCount(id NodeID) int {
+	count := 0
+	for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+		count++
+	}
+	return count
 }
 
 // GetChildren returns all child paths for a given path
 func (jt *JSONTree) GetChildren(path string) []string {
-	return jt.Children[path]
+	id := jt.lookup(path)
+	if id == noNode && path == "" && jt.Stream && len(jt.nodes) > 0 {
+		id = rootID
+	}
+	if id == noNode {
+		return nil
+	}
+
+	var paths []string
+	for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+		paths = append(paths, jt.childPathOf(path, child))
+	}
+	return paths
 }
 
 // HasChildren checks if a path has children
 func (jt *JSONTree) HasChildren(path string) bool {
-	return len(jt.Children[path]) > 0
+	id := jt.lookup(path)
+	return id != noNode && jt.firstChild[id] != noNode
 }
 
 // GetAllPaths returns all paths in the tree
 func (jt *JSONTree) GetAllPaths() []string {
-	paths := make([]string, 0, len(jt.Nodes))
-	for path := range jt.Nodes {
-		paths = append(paths, path)
-	}
+	paths := make([]string, 0, jt.Len())
+	jt.walk(func(id NodeID) {
+		paths = append(paths, jt.Path(id))
+	})
 	return paths
 }
 
 // SetValue updates the value at a given path
 func (jt *JSONTree) SetValue(path string, value interface{}) bool {
-	if node, exists := jt.Nodes[path]; exists {
-		node.Value = value
-		node.Type = getNodeType(value)
-		return true
+	id := jt.lookup(path)
+	if id == noNode {
+		return false
+	}
+
+	jt.removeLinesInside(id)
+	jt.removeDescendants(id)
+	if jt.isPending(id) {
+		jt.spans[id] = pending{}
+	}
+	value, _ = unwrapValue(value)
+	node := &jt.nodes[id]
+	node.Type = getNodeType(value)
+	node.Value = leafValue(value)
+	if isNested(value) {
+		jt.spliceLines(jt.LineNumber(id)+1, func() { jt.buildChildren(id, value) })
+		jt.showSpliced(id)
+	}
+	return true
+}
+
+// Path returns the path of a node, built from the keys of its parents
+func (jt *JSONTree) Path(id NodeID) string {
+	if id == noNode || id == rootID {
+		return ""
+	}
+	return jt.childPathOf(jt.Path(jt.parent[id]), id)
+}
+
+// childPathOf returns the path of a node from the path of its parent
+func (jt *JSONTree) childPathOf(parentPath string, id NodeID) string {
+	node := &jt.nodes[id]
+	if node.IsArrayElement {
+		return buildChildPath(parentPath, strconv.Itoa(elementIndex(node)), true)
+	}
+
+	path := buildChildPath(parentPath, node.Key, false)
+	if node.Occurrence > 0 {
+		path += fmt.Sprintf("#%d", node.Occurrence)
+	}
+	return path
+}
+
+// elementIndex returns the index of an array element from its [i] key
+func elementIndex(node *Node) int {
+	index, _ := strconv.Atoi(strings.Trim(node.Key, "[]"))
+	return index
+}
+
+// lookup returns the ID of the node at path, or noNode
+func (jt *JSONTree) lookup(path string) NodeID {
+	segments, err := parsePath(path)
+	if err != nil {
+		return noNode
+	}
+	id, err := jt.resolve(segments)
+	if err != nil {
+		return noNode
+	}
+	return id
+}
+
+// walk calls fn with every node of the tree, parents before their
+// children, in document order
+func (jt *JSONTree) walk(fn func(id NodeID)) {
+	if len(jt.nodes) == 0 {
+		return
+	}
+
+	var visit func(id NodeID)
+	visit = func(id NodeID) {
+		fn(id)
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			visit(child)
+		}
+	}
+
+	if !jt.Stream {
+		visit(rootID)
+		return
+	}
+	// The root of a stream is not a node of the document
+	for record := jt.firstChild[rootID]; record != noNode; record = jt.nextSibling[record] {
+		visit(record)
+	}
+}
+
+// childIndex returns the index of the children of a container
+func (jt *JSONTree) childIndex(id NodeID) *childIndex {
+	if index, exists := jt.index[id]; exists {
+		return index
+	}
+
+	index := &childIndex{}
+	if jt.nodes[id].Type == ObjectType {
+		index.members = make(map[memberKey]NodeID)
+	}
+	for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+		if index.members != nil {
+			node := &jt.nodes[child]
+			index.members[memberKey{node.Key, node.Occurrence}] = child
+		} else {
+			index.elements = append(index.elements, child)
+		}
+	}
+
+	if jt.index == nil {
+		jt.index = make(map[NodeID]*childIndex)
+	}
+	jt.index[id] = index
+	return index
+}
+
+// addLine adds the next line of the document, the line of the node or of
+// its closing bracket, and returns its handle
+func (jt *JSONTree) addLine(id NodeID, closing bool) int32 {
+	h := jt.lines.add(lineRef{node: id, closing: closing}, !jt.splicing)
+	if jt.splicing {
+		jt.spliced = append(jt.spliced, h)
+	} else {
+		jt.lines.push(h)
+	}
+	return h
+}
+
+// spliceLines inserts the lines added by build at the line pos of the
+// document, hidden. The lines after them are not numbered again.
+func (jt *JSONTree) spliceLines(pos int, build func()) {
+	jt.splicing = true
+	build()
+	jt.lines.insert(pos, jt.spliced)
+	jt.splicing, jt.spliced = false, jt.spliced[:0]
+}
+
+// showSpliced shows the lines spliced inside a node, unless the node or
+// one of its parents is folded
+func (jt *JSONTree) showSpliced(id NodeID) {
+	node := &jt.nodes[id]
+	if isContainer(node) && !jt.collapsed[id] && jt.lines.isShown(node.line) {
+		jt.showInside(id, true)
+	}
+}
+
+// removeLinesInside removes the lines of the children of a node and of
+// its closing bracket
+func (jt *JSONTree) removeLinesInside(id NodeID) {
+	node := &jt.nodes[id]
+	if node.closingLine == 0 {
+		return
+	}
+	jt.lines.remove(jt.LineNumber(id)+1, jt.ClosingLineNumber(id)+1)
+	node.closingLine = 0
+}
+
+// LineNumber returns the line of a node in the unfolded document, -1
+// when it has none, like the root of a stream or a removed node
+func (jt *JSONTree) LineNumber(id NodeID) int {
+	line, found := jt.lines.line(jt.nodes[id].line)
+	if !found {
+		return -1
+	}
+	return line
+}
+
+// ClosingLineNumber returns the line of the closing bracket of an object
+// or an array in the unfolded document, -1 when it has none
+func (jt *JSONTree) ClosingLineNumber(id NodeID) int {
+	line, found := jt.lines.line(jt.nodes[id].closingLine)
+	if !found {
+		return -1
 	}
-	return false
+	return line
 }
 
 // ========== Pretty Printing ==========
 
 // Print returns a formatted string representation
 func (jt *JSONTree) Print(startPath string, indent int) string {
-	node, exists := jt.Nodes[startPath]
-	if !exists {
+	id := jt.lookup(startPath)
+	if id == noNode {
 		return ""
 	}
+	return jt.print(id, indent)
+}
 
+func (jt *JSONTree) print(id NodeID, indent int) string {
+	node := &jt.nodes[id]
 	result := strings.Repeat(" ", indent) + node.Key + ": "
 
-	if jt.IsCollapsed(startPath) {
-		childCount := len(jt.Children[startPath])
-		result += fmt.Sprintf("{...} // %d items\n", childCount)
+	if jt.collapsed[id] {
+		result += fmt.Sprintf("{...} // %d items\n", jt.childCount(id))
 		return result
 	}
 
 	switch node.Type {
 	case ObjectType:
 		result += "{\n"
-		for _, childPath := range jt.Children[startPath] {
-			result += jt.Print(childPath, indent+2)
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			result += jt.print(child, indent+2)
 		}
 		result += strings.Repeat(" ", indent) + "}\n"
 
 	case ArrayType:
 		result += "[\n"
-		for _, childPath := range jt.Children[startPath] {
-			result += jt.Print(childPath, indent+2)
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			result += jt.print(child, indent+2)
 		}
 		result += strings.Repeat(" ", indent) + "]\n"
 
@@ -152,42 +501,46 @@ func (jt *JSONTree) PrintFromRoot() string {
 
 // PrintAsJSON returns the tree as properly formatted JSON
 func (jt *JSONTree) PrintAsJSON(startPath string, indent int) string {
-	node, exists := jt.Nodes[startPath]
-	if !exists {
+	if startPath == "" && jt.Stream && len(jt.nodes) > 0 {
 		// Handle root case when no explicit root node exists
-		if startPath == "" {
-			// Find if root has single object child or multiple children
-			children := jt.Children[startPath]
-			if len(children) == 1 {
-				// Single root object/array
-				return jt.PrintAsJSON(children[0], indent)
-			} else if len(children) > 1 {
-				// Multiple root elements - wrap in object
-				result := "{\n"
-				for i, childPath := range children {
-					if i > 0 {
-						result += ",\n"
-					}
-					childNode := jt.Nodes[childPath]
-					result += strings.Repeat("  ", indent+1) + `"` +
-						keyStyle.Render(childNode.Key) + `": `
-					result += strings.TrimSpace(jt.PrintAsJSON(childPath, indent+1))
+		records := jt.children(rootID)
+		if len(records) == 1 {
+			// Single root object/array
+			return jt.printAsJSON(records[0], indent)
+		} else if len(records) > 1 {
+			// Multiple root elements - wrap in object
+			result := "{\n"
+			for i, record := range records {
+				if i > 0 {
+					result += ",\n"
 				}
-				result += "\n" + strings.Repeat("  ", indent) + "}"
-				jt.currentRealLine++
-				jt.VirtualToRealLines = append(jt.VirtualToRealLines, jt.currentRealLine)
-				return result
+				result += strings.Repeat("  ", indent+1) + `"` +
+					keyStyle.Render(jt.nodes[record].Key) + `": `
+				result += strings.TrimSpace(jt.printAsJSON(record, indent+1))
 			}
+			result += "\n" + strings.Repeat("  ", indent) + "}"
+			jt.currentRealLine++
+			jt.VirtualToRealLines = append(jt.VirtualToRealLines, jt.currentRealLine)
+			return result
 		}
 		return ""
 	}
 
-	jt.currentRealLine = node.LineNumber
+	id := jt.lookup(startPath)
+	if id == noNode {
+		return ""
+	}
+	return jt.printAsJSON(id, indent)
+}
+
+func (jt *JSONTree) printAsJSON(id NodeID, indent int) string {
+	node := &jt.nodes[id]
+	jt.currentRealLine = jt.LineNumber(id)
 	jt.VirtualToRealLines = append(jt.VirtualToRealLines, jt.currentRealLine)
 
-	if jt.IsCollapsed(startPath) {
-		childCount := len(jt.Children[startPath])
-		if jt.Nodes[startPath].Type == ArrayType {
+	if jt.collapsed[id] {
+		childCount := jt.childCount(id)
+		if node.Type == ArrayType {
 			return fmt.Sprintf("[...] // %d items", childCount)
 		}
 		return fmt.Sprintf("{...} // %d properties", childCount)
@@ -195,21 +548,19 @@ func (jt *JSONTree) PrintAsJSON(startPath string, indent int) string {
 
 	switch node.Type {
 	case ObjectType:
-		children := jt.Children[startPath]
-		if len(children) == 0 {
+		if jt.firstChild[id] == noNode {
 			return "{}"
 		}
 
 		result := "{\n"
-		for i, childPath := range children {
-			if i > 0 {
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			if child != jt.firstChild[id] {
 				result += ",\n"
 			}
-			childNode := jt.Nodes[childPath]
 			// Quote the key and add colon
 			result += strings.Repeat("  ", indent+1) + `"` +
-				keyStyle.Render(childNode.Key) + `": `
-			result += strings.TrimSpace(jt.PrintAsJSON(childPath, indent+1))
+				keyStyle.Render(jt.nodes[child].Key) + `": `
+			result += strings.TrimSpace(jt.printAsJSON(child, indent+1))
 		}
 		result += "\n" + strings.Repeat("  ", indent) + "}"
 		jt.currentRealLine++
@@ -217,18 +568,17 @@ func (jt *JSONTree) PrintAsJSON(startPath string, indent int) string {
 		return result
 
 	case ArrayType:
-		children := jt.Children[startPath]
-		if len(children) == 0 {
+		if jt.firstChild[id] == noNode {
 			return "[]"
 		}
 
 		result := "[\n"
-		for i, childPath := range children {
-			if i > 0 {
+		for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+			if child != jt.firstChild[id] {
 				result += ",\n"
 			}
 			result += strings.Repeat("  ", indent+1)
-			result += strings.TrimSpace(jt.PrintAsJSON(childPath, indent+1))
+			result += strings.TrimSpace(jt.printAsJSON(child, indent+1))
 		}
 		result += "\n" + strings.Repeat("  ", indent) + "]"
 		jt.currentRealLine++
@@ -239,12 +589,21 @@ func (jt *JSONTree) PrintAsJSON(startPath string, indent int) string {
 		return stringStyle.Render(
 			`"` + strings.ReplaceAll(node.Value.(string), `"`, `\"`) + `"`)
 
-	case NumberType:
+	case IntegerType, FloatType:
 		return numberStyle.Render(fmt.Sprintf("%v", node.Value))
 
 	case BoolType:
 		return booleanStyle.Render(fmt.Sprintf("%t", node.Value.(bool)))
 
+	case DateTimeType:
+		// JSON has no datetime, so print it as a string
+		return dateTimeStyle.Render(fmt.Sprintf(`"%s"`, node.Value))
+
+	case BytesType:
+		// Like encoding/json, print the bytes as a base64 string
+		return bytesStyle.Render(`"` +
+			base64.StdEncoding.EncodeToString(node.Value.(Bytes)) + `"`)
+
 	case NullType:
 		return nullStyle.Render("null")
 
@@ -262,196 +621,171 @@ func (jt *JSONTree) PrintAsJSONFromRoot() string {
 	return jt.PrintAsJSON("", 0)
 }
 
+// PrintAsJSON2 returns the lines on screen, with the lines inside folded
+// nodes left out
 func (jt *JSONTree) PrintAsJSON2() []LineMetadata {
-	var result []LineMetadata
-	jt.currentRealLine = 0
 	jt.VirtualToRealLines = jt.VirtualToRealLines[:0]
-	jt.collectLines("", 0, &result, true, true)
+	result := make([]LineMetadata, 0, jt.lines.count())
+	jt.lines.eachShown(func(h int32, real int) {
+		result = append(result, jt.line(h, len(result)))
+		jt.VirtualToRealLines = append(jt.VirtualToRealLines, real)
+	})
 	return result
 }
 
-func (jt *JSONTree) collectLines(startPath string, indent int, result *[]LineMetadata, isRoot bool, isLast bool) {
-	node, exists := jt.Nodes[startPath]
-	if !exists {
-		// Handle root case
-		if startPath == "" {
-			children := jt.Children[startPath]
-			for i, childPath := range children {
-				isLastChild := i == len(children)-1
-				jt.collectLines(childPath, indent, result, false, isLastChild)
-			}
-		}
-		return
+// ========== Visible Lines ==========
+
+// VisibleLen returns the number of lines on screen when scrolling the
+// whole document
+func (jt *JSONTree) VisibleLen() int {
+	return jt.lines.count()
+}
+
+// RealLine returns the line in the unfolded document of a line on screen,
+// which must be between 0 and VisibleLen()-1
+func (jt *JSONTree) RealLine(virtual int) int {
+	return jt.lines.real(virtual)
+}
+
+// VirtualLine returns the line on screen of a line of the unfolded
+// document, false when it is inside a folded node
+func (jt *JSONTree) VirtualLine(real int) (int, bool) {
+	if real < 0 || real >= jt.lines.len() {
+		return 0, false
 	}
+	return jt.lines.virtual(jt.lines.at(real))
+}
 
-	switch node.Type {
-	case ObjectType:
-		// Add opening brace
-		if isRoot {
-			openBrace := LineMetadata{
-				LineNumber:  len(*result),
-				LineType:    OpenBracket,
-				Content:     "{",
-				NodePath:    startPath,
-				NodeType:    node.Type,
-				Indent:      indent,
-				BracketChar: "{",
-				IsCollapsed: jt.IsCollapsed(startPath),
-				HasChildren: jt.HasChildren(startPath),
-			}
-			*result = append(*result, openBrace)
-			jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.LineNumber)
-		}
+// Line returns the line on screen at the given virtual line
+func (jt *JSONTree) Line(virtual int) LineMetadata {
+	return jt.line(jt.lines.at(jt.lines.real(virtual)), virtual)
+}
 
-		// Add key line if this isn't root
-		if !isRoot && node.Key != "" {
-			keyLine := LineMetadata{
-				LineNumber:     len(*result),
-				LineType:       ContentWithBrace,
-				Content:        node.Key,
-				NodePath:       startPath,
-				NodeType:       node.Type,
-				Key:            node.Key,
-				Value:          node.Value,
-				IsArrayElement: node.IsArrayElement,
-				Indent:         indent,
-				BracketChar:    "{",
-				IsCollapsed:    jt.IsCollapsed(startPath),
-				HasChildren:    jt.HasChildren(startPath),
-				IsLastChild:    isLast,
-			}
-			*result = append(*result, keyLine)
-			jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.LineNumber)
-		}
+// line builds the metadata of a line of the document, shown at the given
+// virtual line
+func (jt *JSONTree) line(h int32, virtual int) LineMetadata {
+	ref := jt.lines.ref(h)
+	id := ref.node
+	node := &jt.nodes[id]
+
+	// The children of the root are indented, the records of a stream,
+	// which have no root line, are not
+	indent := node.Depth
+	isRoot := id == rootID && !jt.Stream
+	if !isRoot && !jt.Stream {
+		indent++
+	}
+	isLast := isRoot || jt.nextSibling[id] == noNode ||
+		(jt.Stream && jt.parent[id] == rootID)
 
-		// Add children if not collapsed
-		if !jt.IsCollapsed(startPath) {
-			children := jt.Children[startPath]
-			for i, childPath := range children {
-				isLastChild := i == len(children)-1
-				jt.collectLines(childPath, indent+1, result, false, isLastChild)
-			}
+	bracket := "{"
+	if node.Type == ArrayType {
+		bracket = "["
+	}
 
-			// Add closing brace
-			closeBrace := LineMetadata{
-				LineNumber:  len(*result),
-				LineType:    CloseBracket,
-				Content:     "}",
-				NodePath:    startPath,
-				NodeType:    node.Type,
-				Indent:      indent,
-				BracketChar: "}",
-				IsLastChild: isLast,
-			}
-			*result = append(*result, closeBrace)
-			jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.ClosingLineNumber)
+	if ref.closing {
+		closing := "}"
+		if node.Type == ArrayType {
+			closing = "]"
 		}
+		return LineMetadata{
+			LineNumber:  virtual,
+			LineType:    CloseBracket,
+			Content:     closing,
+			NodeID:      id,
+			NodeType:    node.Type,
+			Indent:      indent,
+			BracketChar: closing,
+			IsLastChild: isLast,
+		}
+	}
 
-	case ArrayType:
-		// Add opening brace if it's root
+	line := LineMetadata{
+		LineNumber:     virtual,
+		NodeID:         id,
+		NodeType:       node.Type,
+		Key:            node.Key,
+		Value:          node.Value,
+		Indent:         indent,
+		IsArrayElement: node.IsArrayElement,
+		IsLastChild:    isLast,
+		Change:         jt.Changes[id],
+		Note:           node.Note,
+		Duplicate:      node.Duplicate,
+	}
+
+	switch {
+	case node.Type == ObjectType || node.Type == ArrayType:
+		line.LineType = ContentWithBrace
+		line.Content = node.Key
+		line.BracketChar = bracket
+		line.IsCollapsed = jt.collapsed[id]
+		line.HasChildren = jt.firstChild[id] != noNode || jt.isPending(id)
 		if isRoot {
-			openBrace := LineMetadata{
-				LineNumber:  len(*result),
-				LineType:    OpenBracket,
-				Content:     "[",
-				NodePath:    startPath,
-				NodeType:    node.Type,
-				Indent:      indent,
-				BracketChar: "[",
-				IsCollapsed: jt.IsCollapsed(startPath),
-				HasChildren: jt.HasChildren(startPath),
-			}
-			*result = append(*result, openBrace)
-			jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.LineNumber)
+			line.LineType = OpenBracket
+			line.Content = bracket
+			line.Key = ""
+			line.IsArrayElement = false
+			line.Duplicate = false
 		}
 
-		// Add key line if this isn't root
-		if !isRoot && node.Key != "" {
-			keyLine := LineMetadata{
-				LineNumber:  len(*result),
-				LineType:    ContentWithBrace,
-				Content:     node.Key,
-				NodePath:    startPath,
-				NodeType:    node.Type,
-				Key:         node.Key,
-				Value:       node.Value,
-				Indent:      indent,
-				BracketChar: "[",
-				IsCollapsed: jt.IsCollapsed(startPath),
-				HasChildren: jt.HasChildren(startPath),
-				IsLastChild: isLast,
-			}
-			*result = append(*result, keyLine)
-			jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.LineNumber)
+	case node.Type == StringType:
+		escapedBytes, err := json.Marshal(node.Value)
+		if err != nil {
+			panic(err)
 		}
 
-		// Add opening bracket
-		// openBracket := LineMetadata{
-		// 	LineNumber:  len(*result),
-		// 	LineType:    OpenBracket,
-		// 	Content:     "[",
-		// 	NodePath:    startPath,
-		// 	NodeType:    node.Type,
-		// 	Indent:      indent,
-		// 	BracketChar: "[",
-		// 	IsCollapsed: jt.IsCollapsed(startPath),
-		// 	HasChildren: jt.HasChildren(startPath),
-		// }
-		// *result = append(*result, openBracket)
-
-		// Add children if not collapsed
-		if !jt.IsCollapsed(startPath) {
-			children := jt.Children[startPath]
-			for i, childPath := range children {
-				isLastChild := i == len(children)-1
-				jt.collectLines(childPath, indent+1, result, false, isLastChild)
-			}
-
-			// Add closing bracket
-			closeBracket := LineMetadata{
-				LineNumber:  len(*result),
-				LineType:    CloseBracket,
-				Content:     "]",
-				NodePath:    startPath,
-				NodeType:    node.Type,
-				Indent:      indent,
-				BracketChar: "]",
-				IsLastChild: isLast,
-			}
-			*result = append(*result, closeBracket)
-			jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.ClosingLineNumber)
-		}
+		// Remove outer quotes
+		line.LineType = ContentLine
+		line.Content = string(escapedBytes[1 : len(escapedBytes)-1])
 
 	default:
-		// Primitive values (string, number, boolean, null)
-		valueLine := LineMetadata{
-			LineNumber: len(*result),
-			LineType:   ContentLine,
-			// Content:        fmt.Sprintf("%v", node.Value),
-			NodePath:       startPath,
-			NodeType:       node.Type,
-			Key:            node.Key,
-			Value:          node.Value,
-			Indent:         indent,
-			IsArrayElement: node.IsArrayElement,
-			IsLastChild:    isLast,
-		}
+		// Primitive values (number, boolean, null...)
+		line.LineType = ContentLine
+		line.Content = fmt.Sprintf("%v", node.Value)
+	}
+	return line
+}
 
-		if node.Type == StringType {
-			escapedBytes, err := json.Marshal(node.Value)
-			if err != nil {
-				panic(err)
-			}
+// setCollapsed folds or unfolds a node. Only the lines of the node are
+// hidden or shown again, the rest of the document is left as it is.
+func (jt *JSONTree) setCollapsed(id NodeID, collapsed bool) {
+	if jt.collapsed[id] == collapsed || (jt.loading && id == rootID) {
+		// The root has no closing line until it is loaded
+		return
+	}
+	if !collapsed && jt.load(id) != nil {
+		// The node stays folded, its value cannot be read
+		return
+	}
+	jt.collapsed[id] = collapsed
 
-			// Remove outer quotes
-			valueLine.Content = string(escapedBytes[1 : len(escapedBytes)-1])
-		} else {
-			valueLine.Content = fmt.Sprintf("%v", node.Value)
-		}
+	// The lines inside a folded parent stay hidden, and the root of a
+	// stream has no lines
+	node := &jt.nodes[id]
+	if !isContainer(node) || (jt.Stream && id == rootID) ||
+		!jt.lines.isShown(node.line) {
+		return
+	}
+	jt.showInside(id, !collapsed)
+}
 
-		*result = append(*result, valueLine)
-		jt.VirtualToRealLines = append(jt.VirtualToRealLines, node.LineNumber)
+// showInside shows or hides the lines inside a node, down to the nodes
+// that are folded, and the line of its closing bracket
+func (jt *JSONTree) showInside(id NodeID, shown bool) {
+	for child := jt.firstChild[id]; child != noNode; child = jt.nextSibling[child] {
+		jt.lines.set(jt.nodes[child].line, shown)
+		if isContainer(&jt.nodes[child]) && !jt.collapsed[child] {
+			jt.showInside(child, shown)
+		}
 	}
+	jt.lines.set(jt.nodes[id].closingLine, shown)
+}
+
+// isContainer reports whether the node is an object or an array, which
+// have a line for their closing bracket
+func isContainer(node *Node) bool {
+	return node.Type == ObjectType || node.Type == ArrayType
 }
 
 // ========== Tree Building ==========
@@ -462,92 +796,144 @@ func BuildTree(data interface{}, basePath string, tree *JSONTree) *JSONTree {
 		tree = NewJSONTree()
 	}
 
-	// Create root node if this is the initial call
-	if basePath == "" {
-		rootNode := &Node{
-			Path:           "",
-			Type:           getNodeType(data),
-			Value:          data,
-			Parent:         "",
-			Depth:          0,
-			Key:            "root", // or you could use ""
-			IsArrayElement: false,
-			LineNumber:     0,
+	// The records of a stream are top-level nodes, there is no root
+	if records, ok := data.(Stream); ok {
+		for _, record := range records {
+			tree.AppendRecord(record)
 		}
-		tree.Nodes[""] = rootNode
-		tree.LineNumbers[rootNode.LineNumber] = rootNode
-		tree.lineCounter++
-	}
-
-	createNode := func(path string, value interface{},
-		key string, isParentArray bool) *Node {
-
-		nodeType := getNodeType(value)
-		depth := getDepth(path)
-		isArrayElement := regexp.MustCompile(`\[\d+\]$`).MatchString(path) ||
-			isParentArray
-
-		node := &Node{
-			Path:           path,
-			Type:           nodeType,
-			Value:          value,
-			Parent:         basePath,
-			Depth:          depth,
-			Key:            key,
-			IsArrayElement: isArrayElement,
-			LineNumber:     tree.lineCounter,
+		return tree
+	}
+
+	id := tree.lookup(basePath)
+	if id == noNode {
+		if basePath != "" || len(tree.nodes) > 0 {
+			return tree
 		}
-		tree.lineCounter++
-		return node
+
+		// Create root node if this is the initial call
+		var note string
+		data, note = unwrapValue(data)
+		id = tree.AddChild(noNode, Node{
+			Type:  getNodeType(data),
+			Value: leafValue(data),
+			Depth: 0,
+			Key:   "root", // or you could use ""
+			Note:  note,
+		})
+		tree.nodes[id].line = tree.addLine(id, false)
+	}
+
+	tree.buildChildren(id, data)
+	return tree
+}
+
+// newNode adds a node for value under parent and gives it the next line.
+// Objects and arrays keep no value, their children are added by
+// buildChildren.
+func (tree *JSONTree) newNode(parent NodeID, value interface{},
+	key string, isParentArray bool) NodeID {
+
+	value, note := unwrapValue(value)
+
+	// The root and its children are at depth 0
+	depth := 0
+	if parent != rootID {
+		depth = tree.nodes[parent].Depth + 1
 	}
 
+	id := tree.AddChild(parent, Node{
+		Type:           getNodeType(value),
+		Value:          leafValue(value),
+		Depth:          depth,
+		Key:            key,
+		IsArrayElement: isParentArray,
+		Note:           note,
+	})
+	tree.nodes[id].line = tree.addLine(id, false)
+	if span, ok := value.(pending); ok {
+		tree.pend(id, span)
+	}
+	return id
+}
+
+// buildChildren adds the members of an object or the elements of an
+// array as the children of the node, and the line of its closing bracket
+func (tree *JSONTree) buildChildren(id NodeID, data interface{}) {
+	data, _ = unwrapValue(data)
+
 	// data.(type) syntax is specific to the switch statements
 	// it can be used alone, or with variable assignment, like
 	// in this case, where "v" gets the actual map
 	switch v := data.(type) {
-	case map[string]interface{}:
-		// map[string]interface{} if for JSON objects
-		for key, value := range v {
-			childPath := buildChildPath(basePath, key, false)
-			node := createNode(childPath, value, key, false)
-			tree.Nodes[childPath] = node
-			tree.LineNumbers[node.LineNumber] = node
-			tree.AddChild(basePath, childPath)
+	case Object:
+		// Object is for JSON objects parsed with ParseJSON, the
+		// members are added in the order they appear in the document
+		counts := make(map[string]int, len(v))
+		for _, member := range v {
+			counts[member.Key]++
+		}
+		seen := make(map[string]int)
+		for _, member := range v {
+			child := tree.newNode(id, member.Value, member.Key, false)
+			tree.markDuplicate(child, counts, seen)
 
 			// Recursively build for nested objects/arrays
-			if isNested(value) {
-				BuildTree(value, childPath, tree)
+			if isNested(member.Value) {
+				tree.buildChildren(child, member.Value)
 			}
 		}
+		delete(tree.index, id)
 
-		if node, exists := tree.Nodes[basePath]; exists {
-			node.ClosingLineNumber = tree.lineCounter
+	case map[string]interface{}:
+		// map[string]interface{} has no key order, so sort the keys
+		// to get the same tree on every run
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
 		}
+		sort.Strings(keys)
 
-		tree.lineCounter++ // count the "}"
+		for _, key := range keys {
+			child := tree.newNode(id, v[key], key, false)
+
+			// Recursively build for nested objects/arrays
+			if isNested(v[key]) {
+				tree.buildChildren(child, v[key])
+			}
+		}
 
 	case []interface{}:
 		// []interface{} is for JSON arrays
 		for i, value := range v {
-			key := strconv.Itoa(i)
-			childPath := buildChildPath(basePath, key, true)
-			node := createNode(childPath, value, fmt.Sprintf("[%d]", i), true)
-			tree.Nodes[childPath] = node
-			tree.LineNumbers[node.LineNumber] = node
-			tree.AddChild(basePath, childPath)
+			child := tree.newNode(id, value, fmt.Sprintf("[%d]", i), true)
 
 			// Recursively build for nested objects/arrays
 			if isNested(value) {
-				BuildTree(value, childPath, tree)
+				tree.buildChildren(child, value)
 			}
 		}
 
-		if node, exists := tree.Nodes[basePath]; exists {
-			node.ClosingLineNumber = tree.lineCounter
-		}
-
-		tree.lineCounter++ // count the "]"
+	default:
+		return
 	}
 
-	return tree
+	tree.nodes[id].closingLine = tree.addLine(id, true) // the "}" or "]"
+}
+
+// markDuplicate marks a member whose key is used more than once in its
+// object, counts has the number of members by key and seen the members
+// marked so far. Every occurrence is kept; the last one is the value a
+// JSON parser would return, so it gets the plain path, the others key#1,
+// key#2... The member is marked before the members nested in it, so the
+// duplicates are in document order.
+func (tree *JSONTree) markDuplicate(child NodeID, counts map[string]int, seen map[string]int) {
+	node := &tree.nodes[child]
+	seen[node.Key]++
+	if counts[node.Key] > 1 {
+		node.Duplicate = true
+		if seen[node.Key] < counts[node.Key] {
+			node.Occurrence = seen[node.Key]
+		}
+		tree.Duplicates = append(tree.Duplicates, child)
+	}
 }
@@ -6,16 +6,26 @@ import (
 	"strings"
 	"testing"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/assert"
 )
 
+// mustGetValue returns the value at a path, which must be read without
+// error
+func mustGetValue(t *testing.T, tree *JSONTree, path string) interface{} {
+	t.Helper()
+	value, err := tree.GetValue(path)
+	assert.NoError(t, err)
+	return value
+}
+
 func TestBuildTree_EmptyObject(t *testing.T) {
 	data := map[string]interface{}{}
 	tree := BuildTree(data, "", nil)
 
 	// Should have root node only
 	assert.NotNil(t, tree)
-	assert.Equal(t, 1, len(tree.Nodes)) // Just root
+	assert.Equal(t, 1, tree.Len()) // Just root
 }
 
 func TestBuildTree_Simple(t *testing.T) {
@@ -34,11 +44,18 @@ func TestBuildTree_Simple(t *testing.T) {
 			StringType,
 		},
 		{
-			"simple number",
+			"simple float",
 			map[string]interface{}{"age": 25.0},
 			"age",
 			25.0,
-			NumberType,
+			FloatType,
+		},
+		{
+			"simple integer",
+			map[string]interface{}{"id": json.Number("9007199254740993")},
+			"id",
+			json.Number("9007199254740993"),
+			IntegerType,
 		},
 		{
 			"simple bool",
@@ -59,8 +76,8 @@ func TestBuildTree_Simple(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tree := BuildTree(tt.data, "", nil)
-			assert.Equal(t, tt.expected, tree.GetValue(tt.path))
-			assert.Equal(t, tt.nodeType, tree.Nodes[tt.path].Type)
+			assert.Equal(t, tt.expected, mustGetValue(t, tree, tt.path))
+			assert.Equal(t, tt.nodeType, tree.Node(tt.path).Type)
 		})
 	}
 }
@@ -74,13 +91,14 @@ func TestBuildTree_SimpleArray(t *testing.T) {
 
 	tree := BuildTree(data, "", nil)
 
-	assert.Equal(t, 1, tree.GetValue("elements[0]"))
-	assert.Equal(t, 2.5, tree.GetValue("elements[1]"))
-	assert.Equal(t, "three", tree.GetValue("elements[2]"))
-	assert.Equal(t, NumberType, tree.Nodes["elements[0]"].Type)
-	assert.Equal(t, false, tree.GetValue("elements[3]"))
-	assert.Equal(t, nil, tree.GetValue("elements[4]"))
-	assert.Equal(t, NullType, tree.Nodes["elements[4]"].Type)
+	assert.Equal(t, 1, mustGetValue(t, tree, "elements[0]"))
+	assert.Equal(t, 2.5, mustGetValue(t, tree, "elements[1]"))
+	assert.Equal(t, "three", mustGetValue(t, tree, "elements[2]"))
+	assert.Equal(t, IntegerType, tree.Node("elements[0]").Type)
+	assert.Equal(t, FloatType, tree.Node("elements[1]").Type)
+	assert.Equal(t, false, mustGetValue(t, tree, "elements[3]"))
+	assert.Equal(t, nil, mustGetValue(t, tree, "elements[4]"))
+	assert.Equal(t, NullType, tree.Node("elements[4]").Type)
 }
 
 func TestBuildTree_NestedObject(t *testing.T) {
@@ -92,9 +110,9 @@ func TestBuildTree_NestedObject(t *testing.T) {
 	}
 	tree := BuildTree(data, "", nil)
 
-	assert.Equal(t, "John", tree.GetValue("user.name"))
-	assert.Equal(t, 30.0, tree.GetValue("user.age"))
-	assert.Equal(t, "user", tree.Nodes["user.name"].Parent)
+	assert.Equal(t, "John", mustGetValue(t, tree, "user.name"))
+	assert.Equal(t, 30.0, mustGetValue(t, tree, "user.age"))
+	assert.Equal(t, "user", tree.Path(tree.Parent(tree.Node("user.name").ID)))
 }
 
 func TestBuildTree(t *testing.T) {
@@ -120,22 +138,22 @@ func TestBuildTree(t *testing.T) {
 	}
 	tree := BuildTree(data, "", nil)
 
-	assert.Equal(t, "John", tree.GetValue("user.name"))
-	assert.Equal(t, 30.0, tree.GetValue("user.age"))
-	assert.Equal(t, "user", tree.Nodes["user.name"].Parent)
+	assert.Equal(t, "John", mustGetValue(t, tree, "user.name"))
+	assert.Equal(t, 30.0, mustGetValue(t, tree, "user.age"))
+	assert.Equal(t, "user", tree.Path(tree.Parent(tree.Node("user.name").ID)))
 
-	assert.Equal(t, 1, tree.GetValue("friends[0]"))
-	assert.Equal(t, "friends", tree.Nodes["friends[0]"].Parent)
+	assert.Equal(t, 1, mustGetValue(t, tree, "friends[0]"))
+	assert.Equal(t, "friends", tree.Path(tree.Parent(tree.Node("friends[0]").ID)))
 
 	assert.Equal(t, "passport",
-		tree.GetValue("identifications[0].type"))
+		mustGetValue(t, tree, "identifications[0].type"))
 	assert.Equal(t, "987654321",
-		tree.GetValue("identifications[1].number"))
+		mustGetValue(t, tree, "identifications[1].number"))
 
-	assert.Equal(t, "john@email.com", tree.GetValue("email"))
+	assert.Equal(t, "john@email.com", mustGetValue(t, tree, "email"))
 	assert.Equal(t, "{\"meta\": \"data\"}",
-		tree.GetValue("escaped"))
-	assert.Equal(t, BoolType, tree.Nodes["active"].Type)
+		mustGetValue(t, tree, "escaped"))
+	assert.Equal(t, BoolType, tree.Node("active").Type)
 }
 
 // TODO (isaac): rewrite with the new rendering system
@@ -431,7 +449,7 @@ func TestGetNodeAtLine_CompressedNode(t *testing.T) {
 		for _, realLineNumber := range tree.VirtualToRealLines {
 			actualNode, exists := tree.GetNodeAtLine(realLineNumber)
 			if exists {
-				if actualNode.Path == expectedNode.Path {
+				if actualNode.ID == expectedNode.ID {
 					found++
 				}
 			}
@@ -475,7 +493,7 @@ func TestGetNodeAtLine_CompressedNode2(t *testing.T) {
 		for _, realLineNumber := range tree.VirtualToRealLines {
 			actualNode, exists := tree.GetNodeAtLine(realLineNumber)
 			if exists {
-				if actualNode.Path == expectedNode.Path {
+				if actualNode.ID == expectedNode.ID {
 					found++
 				}
 			}
@@ -484,3 +502,178 @@ func TestGetNodeAtLine_CompressedNode2(t *testing.T) {
 	assert.Equal(t, 1, found,
 		"Expected node not properly found using VirtualToRealLines")
 }
+
+func TestJSONTree_NodeIDs(t *testing.T) {
+	tree := mustLoadTree(t, `{"a": {"b": [1, {"c": "x"}]}, "d": true}`)
+
+	// The shape of the tree is in the node arrays, in document order
+	node := tree.Node("a.b[1].c")
+	assert.Equal(t, "a.b[1].c", tree.Path(node.ID))
+	assert.Equal(t, "a.b[1]", tree.Path(tree.Parent(node.ID)))
+	assert.Equal(t, noNode, tree.Parent(rootID))
+	assert.Equal(t, 7, tree.Len())
+
+	// Containers keep no value, it is built again from the children
+	assert.Nil(t, tree.Node("a").Value)
+	assert.Equal(t, Object{{Key: "b", Value: []interface{}{json.Number("1"),
+		Object{{Key: "c", Value: "x"}}}}}, mustGetValue(t, tree, "a"))
+
+	// Removed nodes are not counted or found
+	assert.True(t, tree.SetValue("a", "y"))
+	assert.Equal(t, 3, tree.Len())
+	assert.Nil(t, tree.Node("a.b"))
+	assert.Equal(t, 2, tree.LineNumber(tree.Node("d").ID))
+	assertLines(t, tree)
+}
+
+// assertLines checks the lines of the tree against the lines found by
+// walking it: every node and closing bracket in document order, the lines
+// inside folded nodes hidden
+func assertLines(t *testing.T, tree *JSONTree) {
+	t.Helper()
+	var all, shown []lineRef
+	var walk func(id NodeID, visible bool)
+	walk = func(id NodeID, visible bool) {
+		add := func(ref lineRef) {
+			all = append(all, ref)
+			if visible {
+				shown = append(shown, ref)
+			}
+		}
+		add(lineRef{node: id})
+		assert.Equal(t, len(all)-1, tree.LineNumber(id), "line of .%s", tree.Path(id))
+		if isContainer(&tree.nodes[id]) {
+			visible = visible && !tree.collapsed[id]
+			for child := tree.firstChild[id]; child != noNode; child = tree.nextSibling[child] {
+				walk(child, visible)
+			}
+			add(lineRef{node: id, closing: true})
+			assert.Equal(t, len(all)-1, tree.ClosingLineNumber(id), "closing line of .%s", tree.Path(id))
+		}
+	}
+	if tree.Stream {
+		for record := tree.firstChild[rootID]; record != noNode; record = tree.nextSibling[record] {
+			walk(record, true)
+		}
+	} else if tree.Len() > 0 {
+		walk(rootID, true)
+	}
+
+	var lines []lineRef
+	tree.lines.eachShown(func(h int32, _ int) {
+		lines = append(lines, tree.lines.ref(h))
+	})
+	assert.Equal(t, len(all), tree.lines.len())
+	assert.Equal(t, shown, lines)
+}
+
+func TestJSONTree_FoldSplicesLines(t *testing.T) {
+	tree := mustLoadTree(t,
+		`{"a": {"b": [1, {"c": "x"}], "e": {}}, "d": [true, [2, 3]]}`)
+	lines := func() []string {
+		var contents []string
+		for _, line := range tree.PrintAsJSON2() {
+			contents = append(contents, string(line.LineType)+" "+line.Content)
+		}
+		return contents
+	}
+
+	// Folding a parent and then a child, in any order, shows the same
+	// lines as walking the tree
+	folds := []struct {
+		path      string
+		collapsed bool
+	}{
+		{"a.b[1]", true}, {"a", true}, {"d[1]", true}, {"a", false},
+		{"a.e", true}, {"d", true}, {"a.b[1]", false}, {"d", false},
+	}
+	for _, fold := range folds {
+		if fold.collapsed {
+			tree.Collapse(fold.path)
+		} else {
+			tree.Expand(fold.path)
+		}
+		assertLines(t, tree)
+	}
+
+	assert.Equal(t, []string{
+		"open_bracket {", "content_with_brace a", "content_with_brace b",
+		"content 1", "content_with_brace [1]", "content x", "close_bracket }",
+		"close_bracket ]", "content_with_brace e", "close_bracket }",
+		"content_with_brace d", "content true", "content_with_brace [1]",
+		"close_bracket ]", "close_bracket }",
+	}, lines())
+
+	// The closing line of the folded "e" is skipped
+	assert.Equal(t, 10, tree.RealLine(9))
+	virtual, found := tree.VirtualLine(tree.LineNumber(tree.Node("d[1]").ID))
+	assert.True(t, found)
+	assert.Equal(t, 12, virtual)
+	_, found = tree.VirtualLine(tree.LineNumber(tree.Node("d[1][0]").ID))
+	assert.False(t, found)
+}
+
+// benchmarkDocument returns a JSON array of n records, each with nested
+// objects and arrays, about 300 bytes per record
+func benchmarkDocument(n int) []byte {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := range n {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "user %d", "email": "user%d@example.com",`+
+			` "active": %t, "score": %d.5, "tags": ["a", "b", "c"],`+
+			` "address": {"street": "%d Main St", "city": "Springfield", "zip": "%05d"},`+
+			` "orders": [{"id": %d, "total": 12.5}, {"id": %d, "total": 7}]}`,
+			i, i, i, i%2 == 0, i, i, i, i*2, i*2+1)
+	}
+	b.WriteString("]")
+	return []byte(b.String())
+}
+
+func BenchmarkBuildTree(b *testing.B) {
+	value, err := ParseJSON(strings.NewReader(string(benchmarkDocument(10000))))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		BuildTree(value, "", nil)
+	}
+}
+
+func BenchmarkPrintAsJSON2(b *testing.B) {
+	tree, err := loadTree(benchmarkDocument(10000), options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		tree.PrintAsJSON2()
+	}
+}
+
+func BenchmarkFoldUnfold(b *testing.B) {
+	tree, err := loadTree(benchmarkDocument(10000), options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	updated, _ := model{tree: tree}.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	m := updated.(model)
+
+	// Fold and unfold a record in the middle of the document
+	m.commandBuffer = ".[5000]"
+	updated, _ = m.runCommand()
+	m = updated.(model)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+		m = updated.(model)
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+		m = updated.(model)
+	}
+}
@@ -2,31 +2,59 @@ package main
 
 import (
 	"fmt"
+	"strings"
 )
 
 func usage() string {
 	return fmt.Sprintf(`vj - JSON viewer version %v
 
 Usage: vj [file]
+   or: vj https://host/api/items [-H 'Name: value']
+   or: vj --cmd 'kubectl get pods -o json'
    or: curl ... | vj
+   or: kubectl get pods -o yaml | vj --format yaml
+   or: curl -i ... | vj --format text
+   or: vj -f file.ndjson
+   or: tail -f file.ndjson | vj --follow
 
 Arguments:
    -h, --help            print help
    -v, --version         print version
+   --ndjson              read the input as newline delimited JSON records
+   -f, --follow          keep reading records as they are written
+   --lenient             accept JSONC, or JSON5 in .json5 files
+   --format FORMAT       input format, detected when not given:
+                         %s
+   --delimiter CHAR      CSV cell separator, for example ';' or tab
+   --no-header           the first CSV row is data, not the keys
+   --infer-types         read CSV numbers and booleans instead of strings
+   -H, --header HEADER   add a "Name: value" header to the request of a URL
+   -X, --request METHOD  method of the request, GET or POST with a body
+   --body-file FILE      send the content of the file as the request body
+   --cmd COMMAND         view the output of a shell command, r runs it again
 
 Key bindings:
-   h, ←                  fold JSON object or array
-   l, →                  unfold JSON object or array
+   h, ←                  fold JSON object or array, or re-encode a decoded string
+   l, →                  unfold JSON object or array, or decode a JSON string
+   d                     decode a JWT, URL, hex or base64 string
    j, ↓                  move cursor down
    k, ↑                  move cursor up
    5j                    move cursor 5 lines down from current position
    5k                    move cursor 5 lines up from current position
-   {                     move cursor to previous sibling
-   }                     move cursor to next sibling
+   {                     move cursor to previous sibling or record
+   }                     move cursor to next sibling or record
    g                     move cursor to the first line of the document
    G                     move cursor to the last line of the document
+   r                     run the command again, or reload the URL or the file
    :                     switch to command mode
-   :.                    find path in JSON, for example :.users[0].email
-   :q                    quit`, version,
+   :.                    find path in JSON, for example :.users[0].email or :.["a.b"]
+   :/                    find a JSON Pointer, for example :/users/0/email
+   :error                show the parse error again after browsing invalid input
+   :dup                  move cursor to the next duplicate key
+   :reload               same as r
+   :run COMMAND          view the output of a shell command
+   :set                  list the value annotations: timestamps ages colors sizes
+   :set noNAME, NAME!    turn an annotation off, or toggle it
+   :q                    quit`, version, strings.Join(formatNames(), ", "),
 	)
 }
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAML reads a YAML stream and returns one value per document.
+// Documents are converted from the yaml.Node tree, so mappings keep their
+// key order. Aliases are resolved, and the alias and anchor names are kept
+// as notes, together with the comments of the source.
+//
+// On invalid input it returns a *ParseError and the documents read before
+// the error.
+func ParseYAML(src []byte) ([]interface{}, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+
+	var docs []interface{}
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, yamlParseError(src, err)
+		}
+
+		c := yamlConverter{}
+		value, err := c.convert(&node)
+		if err != nil {
+			return docs, &ParseError{Err: err, Offset: lineOffset(src, node.Line)}
+		}
+		docs = append(docs, value)
+	}
+}
+
+// Aliases may expand to a lot more nodes than the document has, like in
+// the "billion laughs" attack. As yaml.v3 does when it decodes values, the
+// part of the nodes that come from aliases is limited, less so for small
+// documents.
+const (
+	yamlAliasRatioLow  = 400000  // nodes up to which 99% may come from aliases
+	yamlAliasRatioHigh = 4000000 // nodes from which 10% may
+)
+
+// yamlConverter converts the nodes of a document. visiting holds the
+// aliased nodes being converted, to stop on recursive aliases.
+type yamlConverter struct {
+	visiting []*yaml.Node
+	nodes    int // converted so far
+	aliased  int // converted inside an alias
+}
+
+// allowedAliasRatio returns the part of the nodes that may come from
+// aliases, after the given number of nodes
+func allowedAliasRatio(nodes int) float64 {
+	switch {
+	case nodes <= yamlAliasRatioLow:
+		return 0.99
+	case nodes >= yamlAliasRatioHigh:
+		return 0.10
+	}
+	return 0.99 - 0.89*float64(nodes-yamlAliasRatioLow)/
+		float64(yamlAliasRatioHigh-yamlAliasRatioLow)
+}
+
+// convert converts a node to the values used by BuildTree
+func (c *yamlConverter) convert(node *yaml.Node) (interface{}, error) {
+	c.nodes++
+	if len(c.visiting) > 0 {
+		c.aliased++
+	}
+	if c.aliased > 100 && c.nodes > 1000 &&
+		float64(c.aliased)/float64(c.nodes) > allowedAliasRatio(c.nodes) {
+		return nil, fmt.Errorf("line %d: the aliases expand to too many nodes", node.Line)
+	}
+
+	value, err := c.convertValue(node)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []string
+	if node.Anchor != "" {
+		notes = append(notes, "&"+node.Anchor)
+	}
+	if node.Kind == yaml.AliasNode {
+		notes = append(notes, "*"+node.Value)
+	}
+	notes = append(notes, yamlComments(node)...)
+
+	if len(notes) > 0 {
+		value = annotate(value, notes)
+	}
+	return value, nil
+}
+
+func (c *yamlConverter) convertValue(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return c.convert(node.Content[0])
+
+	case yaml.AliasNode:
+		if slices.Contains(c.visiting, node.Alias) {
+			return nil, fmt.Errorf("line %d: recursive alias *%s", node.Line, node.Value)
+		}
+		c.visiting = append(c.visiting, node.Alias)
+		value, err := c.convertValue(node.Alias)
+		c.visiting = c.visiting[:len(c.visiting)-1]
+		if err != nil {
+			return nil, err
+		}
+		// The alias shows the anchor name, not the notes of the anchor
+		value, _ = unwrapValue(value)
+		return value, nil
+
+	case yaml.MappingNode:
+		return c.convertMapping(node)
+
+	case yaml.SequenceNode:
+		arr := []interface{}{}
+		for _, item := range node.Content {
+			value, err := c.convert(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+
+	case yaml.ScalarNode:
+		return convertYAMLScalar(node), nil
+	}
+
+	return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
+
+// convertMapping converts a mapping. The members of merge keys
+// ("<<: *defaults") are added where the merge key is, unless the mapping
+// sets the same key itself.
+func (c *yamlConverter) convertMapping(node *yaml.Node) (interface{}, error) {
+	explicit := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "<<" {
+			explicit[node.Content[i].Value] = true
+		}
+	}
+
+	obj := Object{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		if keyNode.Value == "<<" && keyNode.ShortTag() == "!!merge" {
+			merged, err := c.convertMerge(valueNode)
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range merged {
+				if !explicit[member.Key] {
+					obj = append(obj, member)
+					explicit[member.Key] = true
+				}
+			}
+			continue
+		}
+
+		value, err := c.convert(valueNode)
+		if err != nil {
+			return nil, err
+		}
+
+		// Comments above the key and on its line belong to the member
+		if comments := yamlComments(keyNode); len(comments) > 0 {
+			var note string
+			value, note = unwrapValue(value)
+			if note != "" {
+				comments = append(comments, note)
+			}
+			value = annotate(value, comments)
+		}
+		obj = append(obj, Member{Key: keyNode.Value, Value: value})
+	}
+
+	return obj, nil
+}
+
+func (c *yamlConverter) convertMerge(node *yaml.Node) (Object, error) {
+	sources := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		sources = node.Content
+	}
+
+	var merged Object
+	for _, source := range sources {
+		value, err := c.convert(source)
+		if err != nil {
+			return nil, err
+		}
+
+		value, note := unwrapValue(value)
+		obj, ok := value.(Object)
+		if !ok {
+			return nil, fmt.Errorf("line %d: merge value is not a mapping", source.Line)
+		}
+
+		// Show where the merged members come from
+		for _, member := range obj {
+			if note != "" {
+				member.Value = annotate(member.Value, []string{"<< " + note})
+			}
+			merged = append(merged, member)
+		}
+	}
+	return merged, nil
+}
+
+func convertYAMLScalar(node *yaml.Node) interface{} {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil
+
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err == nil {
+			return b
+		}
+
+	case "!!int":
+		literal := strings.ReplaceAll(node.Value, "_", "")
+		literal = strings.Replace(literal, "0o", "0", 1)
+		if n, ok := new(big.Int).SetString(literal, 0); ok {
+			return json.Number(n.String())
+		}
+
+	case "!!float":
+		return yamlFloat(node.Value)
+	}
+
+	return node.Value
+}
+
+// yamlFloat writes a YAML float as a JSON number literal, keeping the
+// original digits when they are already valid JSON
+func yamlFloat(literal string) json.Number {
+	switch strings.ToLower(strings.TrimPrefix(literal, "+")) {
+	case ".inf":
+		return "Infinity"
+	case "-.inf":
+		return "-Infinity"
+	case ".nan":
+		return "NaN"
+	}
+
+	var number json.Number
+	if json.Unmarshal([]byte(literal), &number) == nil {
+		return number
+	}
+
+	f, err := strconv.ParseFloat(strings.ReplaceAll(literal, "_", ""), 64)
+	if err != nil {
+		return json.Number(literal)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func yamlComments(node *yaml.Node) []string {
+	var comments []string
+	for _, comment := range []string{node.HeadComment, node.LineComment} {
+		if comment != "" {
+			comments = append(comments, strings.Join(strings.Fields(comment), " "))
+		}
+	}
+	return comments
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+): (.*)`)
+
+// Errors of the yaml parser, as opposed to its scanner. They report the
+// line where the enclosing block starts, counted from 0.
+var yamlParserProblems = []string{
+	"did not find expected <", "did not find expected node content",
+	"did not find expected '-' indicator", "did not find expected key",
+	"did not find expected ','", "found undefined tag handle",
+	"found duplicate %", "found incompatible YAML document",
+}
+
+// yamlParseError turns a yaml error into a *ParseError. The yaml package
+// only reports the line, so the error points at its first column.
+func yamlParseError(src []byte, err error) *ParseError {
+	parseErr := &ParseError{Err: err, Offset: int64(len(src))}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		return parseErr
+	}
+
+	if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		for _, problem := range yamlParserProblems {
+			if strings.HasPrefix(match[2], problem) {
+				line++
+				break
+			}
+		}
+		parseErr.Offset = lineOffset(src, line)
+	}
+	return parseErr
+}
+
+// lineOffset returns the byte offset of the start of a 1-based line
+func lineOffset(src []byte, line int) int64 {
+	offset := 0
+	for ; line > 1; line-- {
+		next := bytes.IndexByte(src[offset:], '\n')
+		if next < 0 {
+			return int64(len(src))
+		}
+		offset += next + 1
+	}
+	return int64(offset)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYAML(t *testing.T) {
+	input := `name: web
+replicas: 3
+ratio: 0.5
+port: 0x1F90
+enabled: yes
+debug: false
+empty: ~
+tags: [a, b]
+limits:
+  cpu: .inf
+`
+	docs, err := ParseYAML([]byte(input))
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+
+	obj := docs[0].(Object)
+	var keys []string
+	for _, member := range obj {
+		keys = append(keys, member.Key)
+	}
+	// Keys keep the order of the document
+	assert.Equal(t, []string{"name", "replicas", "ratio", "port", "enabled",
+		"debug", "empty", "tags", "limits"}, keys)
+
+	assert.Equal(t, "web", obj[0].Value)
+	assert.Equal(t, json.Number("3"), obj[1].Value)
+	assert.Equal(t, json.Number("0.5"), obj[2].Value)
+	assert.Equal(t, json.Number("8080"), obj[3].Value)
+	// yes is a string in YAML 1.2
+	assert.Equal(t, "yes", obj[4].Value)
+	assert.Equal(t, false, obj[5].Value)
+	assert.Nil(t, obj[6].Value)
+	assert.Equal(t, []interface{}{"a", "b"}, obj[7].Value)
+	assert.Equal(t, Object{{Key: "cpu", Value: json.Number("Infinity")}}, obj[8].Value)
+}
+
+func TestParseYAML_MultipleDocuments(t *testing.T) {
+	input := "kind: Service\n---\nkind: Deployment\n---\n- 1\n"
+
+	tree, err := loadTree([]byte(input), options{format: "yaml"})
+	assert.NoError(t, err)
+	assert.True(t, tree.Stream)
+	assert.Equal(t, []string{"0", "1", "2"}, tree.GetChildren(""))
+	assert.Equal(t, "Service", mustGetValue(t, tree, "0.kind"))
+	assert.Equal(t, "Deployment", mustGetValue(t, tree, "1.kind"))
+	assert.Equal(t, IntegerType, tree.Node("2[0]").Type)
+
+	// A single document is a regular document
+	tree, err = loadTree([]byte("kind: Service\n"), options{format: "yaml"})
+	assert.NoError(t, err)
+	assert.False(t, tree.Stream)
+	assert.Equal(t, "Service", mustGetValue(t, tree, "kind"))
+}
+
+func TestParseYAML_Aliases(t *testing.T) {
+	input := `defaults: &defaults
+  image: nginx
+  port: 80
+web:
+  <<: *defaults
+  port: 8080
+copy: *defaults
+`
+	tree, err := loadTree([]byte(input), options{format: "yaml"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "&defaults", tree.Node("defaults").Note)
+
+	// The alias is resolved and shows where it comes from
+	assert.Equal(t, "*defaults", tree.Node("copy").Note)
+	assert.Equal(t, ObjectType, tree.Node("copy").Type)
+	assert.Equal(t, "nginx", mustGetValue(t, tree, "copy.image"))
+
+	// Merged keys are added unless the mapping sets them
+	assert.Equal(t, []string{"web.image", "web.port"}, tree.GetChildren("web"))
+	assert.Equal(t, "nginx", mustGetValue(t, tree, "web.image"))
+	assert.Equal(t, "<< *defaults", tree.Node("web.image").Note)
+	assert.Equal(t, json.Number("8080"), mustGetValue(t, tree, "web.port"))
+}
+
+func TestParseYAML_AliasBomb(t *testing.T) {
+	// Each list holds the previous one nine times, 9^9 strings in all
+	input := "a: &a [\"lol\", \"lol\", \"lol\", \"lol\", \"lol\", \"lol\", \"lol\", \"lol\", \"lol\"]\n"
+	for c := 'b'; c <= 'i'; c++ {
+		prev := string(c - 1)
+		input += fmt.Sprintf("%c: &%c [*%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s]\n",
+			c, c, prev, prev, prev, prev, prev, prev, prev, prev, prev)
+	}
+
+	_, err := ParseYAML([]byte(input))
+	assert.ErrorContains(t, err, "the aliases expand to too many nodes")
+}
+
+func TestParseYAML_Comments(t *testing.T) {
+	input := "# the service name\nname: web # public\n"
+
+	tree, err := loadTree([]byte(input), options{format: "yaml"})
+	assert.NoError(t, err)
+	assert.Equal(t, "# the service name # public", tree.Node("name").Note)
+}
+
+func TestParseYAML_Error(t *testing.T) {
+	input := "a: 1\n---\nb: [1, 2\nc: 3\n"
+
+	tree, err := loadTree([]byte(input), options{format: "yaml"})
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	parseErr.Locate([]byte(input))
+	// The error points at the line of the unclosed sequence
+	assert.Equal(t, 3, parseErr.Line)
+	assert.Equal(t, 1, parseErr.Column)
+
+	// The documents before the error can be browsed
+	assert.NotNil(t, tree)
+	assert.Equal(t, json.Number("1"), mustGetValue(t, tree, "a"))
+}